@@ -0,0 +1,247 @@
+// Package analyses keeps the stock analyses served by /api/stock/analyze
+// together with the prompt variant that produced them, so their outcome
+// can be recorded later and compared per variant.
+package analyses
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"stock-analysis-api/cache"
+	"stock-analysis-api/ids"
+	"stock-analysis-api/tradeplan"
+)
+
+var ErrNotFound = errors.New("analysis not found")
+
+// Outcome results.
+const (
+	OutcomeTargetHit = "target_hit"
+	OutcomeStopHit   = "stop_hit"
+	OutcomeExpired   = "expired"
+)
+
+// Record is one stored analysis.
+type Record struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	StockCode string    `json:"stock_code"`
+	Date      string    `json:"date"`
+	// PromptVersion is the template ID, e.g. "analyze/v1", and Experiment
+	// the experiment that chose it, if any.
+	PromptVersion string               `json:"prompt_version"`
+	Experiment    string               `json:"experiment,omitempty"`
+	Model         string               `json:"model,omitempty"`
+	Decision      string               `json:"decision,omitempty"`
+	TradePlan     *tradeplan.TradePlan `json:"trade_plan,omitempty"`
+	Outcome       *Outcome             `json:"outcome,omitempty"`
+}
+
+// Outcome is what happened to the trade after the analysis.
+type Outcome struct {
+	Result string `json:"result"`
+	// ReturnPct is the realized return in percent, negative for a loss.
+	ReturnPct  float64   `json:"return_pct"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Validate checks Result.
+func (o *Outcome) Validate() error {
+	switch o.Result {
+	case OutcomeTargetHit, OutcomeStopHit, OutcomeExpired:
+		return nil
+	}
+	return fmt.Errorf("outcome result must be %s, %s or %s", OutcomeTargetHit, OutcomeStopHit, OutcomeExpired)
+}
+
+// VariantReport aggregates the analyses of one prompt version.
+type VariantReport struct {
+	Experiment    string `json:"experiment,omitempty"`
+	PromptVersion string `json:"prompt_version"`
+	Analyses      int    `json:"analyses"`
+	WithOutcome   int    `json:"with_outcome"`
+	TargetHits    int    `json:"target_hits"`
+	StopHits      int    `json:"stop_hits"`
+	Expired       int    `json:"expired"`
+	// HitRate is TargetHits over WithOutcome; AvgReturnPct averages the
+	// recorded returns.
+	HitRate      float64 `json:"hit_rate"`
+	AvgReturnPct float64 `json:"avg_return_pct"`
+
+	totalReturn float64
+}
+
+// Store persists analyses. Get returns a copy.
+type Store interface {
+	Save(r *Record) error
+	Get(id string) (*Record, error)
+	SetOutcome(id string, o Outcome) (*Record, error)
+	Report() ([]VariantReport, error)
+}
+
+// MemoryStore keeps analyses in process memory and forgets those older
+// than ttl.
+type MemoryStore struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, records: make(map[string]*Record)}
+}
+
+// recordTTL is how long analyses are kept; outcomes are usually known
+// within a few weeks of the analysis.
+const recordTTL = 60 * 24 * time.Hour
+
+var (
+	defaultOnce  sync.Once
+	defaultStore Store
+	defaultErr   error
+)
+
+// Default returns the process-wide store used by the API handlers: Redis
+// when STORE_URL is set, otherwise process memory.
+func Default() (Store, error) {
+	defaultOnce.Do(func() {
+		shared, err := cache.Shared()
+		switch {
+		case err != nil:
+			defaultErr = err
+		case shared != nil:
+			defaultStore = NewRedisStore(shared, recordTTL)
+		default:
+			defaultStore = NewMemoryStore(recordTTL)
+		}
+	})
+	return defaultStore, defaultErr
+}
+
+// Save stores r, assigning ID and CreatedAt.
+func (m *MemoryStore) Save(r *Record) error {
+	id, err := ids.New()
+	if err != nil {
+		return err
+	}
+	r.ID = id
+	r.CreatedAt = time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expireLocked(r.CreatedAt)
+	saved := *r
+	m.records[id] = &saved
+	return nil
+}
+
+func (m *MemoryStore) Get(id string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expireLocked(time.Now())
+
+	r, ok := m.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	c := *r
+	return &c, nil
+}
+
+// SetOutcome records o for the analysis id, replacing an earlier one.
+func (m *MemoryStore) SetOutcome(id string, o Outcome) (*Record, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	o.RecordedAt = time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	r.Outcome = &o
+	c := *r
+	return &c, nil
+}
+
+// Report aggregates the stored analyses per experiment and prompt version.
+func (m *MemoryStore) Report() ([]VariantReport, error) {
+	m.mu.Lock()
+	m.expireLocked(time.Now())
+	records := make([]*Record, 0, len(m.records))
+	for _, r := range m.records {
+		records = append(records, r)
+	}
+	out := report(records)
+	m.mu.Unlock()
+	return out, nil
+}
+
+// report aggregates records per experiment and prompt version.
+func report(records []*Record) []VariantReport {
+	byVariant := map[[2]string]*VariantReport{}
+	for _, r := range records {
+		key := [2]string{r.Experiment, r.PromptVersion}
+		v, ok := byVariant[key]
+		if !ok {
+			v = &VariantReport{Experiment: r.Experiment, PromptVersion: r.PromptVersion}
+			byVariant[key] = v
+		}
+		v.add(r)
+	}
+
+	out := make([]VariantReport, 0, len(byVariant))
+	for _, v := range byVariant {
+		if v.WithOutcome > 0 {
+			v.HitRate = round(float64(v.TargetHits) / float64(v.WithOutcome))
+			v.AvgReturnPct = round(v.totalReturn / float64(v.WithOutcome))
+		}
+		out = append(out, *v)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Experiment != out[j].Experiment {
+			return out[i].Experiment < out[j].Experiment
+		}
+		return out[i].PromptVersion < out[j].PromptVersion
+	})
+	return out
+}
+
+func (v *VariantReport) add(r *Record) {
+	v.Analyses++
+	if r.Outcome == nil {
+		return
+	}
+	v.WithOutcome++
+	v.totalReturn += r.Outcome.ReturnPct
+	switch r.Outcome.Result {
+	case OutcomeTargetHit:
+		v.TargetHits++
+	case OutcomeStopHit:
+		v.StopHits++
+	case OutcomeExpired:
+		v.Expired++
+	}
+}
+
+func (m *MemoryStore) expireLocked(now time.Time) {
+	if m.ttl <= 0 {
+		return
+	}
+	for id, r := range m.records {
+		if now.Sub(r.CreatedAt) > m.ttl {
+			delete(m.records, id)
+		}
+	}
+}
+
+func round(x float64) float64 {
+	return math.Round(x*10000) / 10000
+}
@@ -0,0 +1,139 @@
+package analyses
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"stock-analysis-api/cache"
+	"stock-analysis-api/ids"
+)
+
+// RedisStore keeps analyses in Redis. Each record is a JSON value under
+// "analysis:<id>" that expires ttl after it was created; "analyses"
+// indexes the IDs by creation time.
+type RedisStore struct {
+	redis *cache.Redis
+	ttl   time.Duration
+}
+
+func NewRedisStore(r *cache.Redis, ttl time.Duration) *RedisStore {
+	return &RedisStore{redis: r, ttl: ttl}
+}
+
+const redisIndexKey = "analyses"
+
+// reportBatch is how many records Report reads per MGET.
+const reportBatch = 500
+
+func redisRecordKey(id string) string {
+	return "analysis:" + id
+}
+
+// Save stores r, assigning ID and CreatedAt.
+func (s *RedisStore) Save(r *Record) error {
+	id, err := ids.New()
+	if err != nil {
+		return err
+	}
+	r.ID = id
+	r.CreatedAt = time.Now()
+
+	ctx := context.Background()
+	value, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode analysis: %v", err)
+	}
+	_, err = s.redis.Do(ctx, "SET", redisRecordKey(id), string(value),
+		"PX", strconv.FormatInt(s.ttl.Milliseconds(), 10))
+	if err != nil {
+		return fmt.Errorf("failed to store analysis: %v", err)
+	}
+	score := strconv.FormatInt(r.CreatedAt.UnixMilli(), 10)
+	if _, err := s.redis.Do(ctx, "ZADD", redisIndexKey, score, id); err != nil {
+		return fmt.Errorf("failed to index analysis: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) Get(id string) (*Record, error) {
+	value, ok, err := s.redis.Get(context.Background(), redisRecordKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analysis: %v", err)
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var r Record
+	if err := json.Unmarshal(value, &r); err != nil {
+		return nil, fmt.Errorf("failed to decode analysis: %v", err)
+	}
+	return &r, nil
+}
+
+// SetOutcome records o for the analysis id, replacing an earlier one. The
+// record keeps its original expiry.
+func (s *RedisStore) SetOutcome(id string, o Outcome) (*Record, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	o.RecordedAt = time.Now()
+
+	r, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	r.Outcome = &o
+	value, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode analysis: %v", err)
+	}
+	reply, err := s.redis.Do(context.Background(), "SET", redisRecordKey(id), string(value), "XX", "KEEPTTL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to store outcome: %v", err)
+	}
+	if reply == nil {
+		return nil, ErrNotFound
+	}
+	return r, nil
+}
+
+// Report aggregates the stored analyses per experiment and prompt version.
+func (s *RedisStore) Report() ([]VariantReport, error) {
+	ctx := context.Background()
+	// Drop index entries whose record has expired.
+	cutoff := strconv.FormatInt(time.Now().Add(-s.ttl).UnixMilli(), 10)
+	if _, err := s.redis.Do(ctx, "ZREMRANGEBYSCORE", redisIndexKey, "-inf", "("+cutoff); err != nil {
+		return nil, fmt.Errorf("failed to read analyses: %v", err)
+	}
+	ids, err := s.redis.Strings(ctx, "ZRANGE", redisIndexKey, "0", "-1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analyses: %v", err)
+	}
+
+	var records []*Record
+	for start := 0; start < len(ids); start += reportBatch {
+		end := min(start+reportBatch, len(ids))
+		args := []string{"MGET"}
+		for _, id := range ids[start:end] {
+			args = append(args, redisRecordKey(id))
+		}
+		values, err := s.redis.Strings(ctx, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read analyses: %v", err)
+		}
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+			var r Record
+			if err := json.Unmarshal([]byte(value), &r); err != nil {
+				continue
+			}
+			records = append(records, &r)
+		}
+	}
+	return report(records), nil
+}
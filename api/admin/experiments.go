@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"stock-analysis-api/analyses"
+	"stock-analysis-api/auth"
+	"stock-analysis-api/prompts"
+)
+
+type OutcomeRequest struct {
+	AnalysisID string `json:"analysis_id"`
+	// Result is target_hit, stop_hit or expired.
+	Result    string  `json:"result"`
+	ReturnPct float64 `json:"return_pct"`
+}
+
+type ExperimentsResponse struct {
+	Status      string                   `json:"status"`
+	Experiments []prompts.Experiment     `json:"experiments,omitempty"`
+	Variants    []analyses.VariantReport `json:"variants,omitempty"`
+	Analysis    *analyses.Record         `json:"analysis,omitempty"`
+	Error       string                   `json:"error,omitempty"`
+}
+
+// Experiments reports the prompt experiments with the accuracy of each
+// variant on GET, and records the outcome of a stored analysis on POST.
+func Experiments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Admin-Token")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := auth.Admin(r); err != nil {
+		w.WriteHeader(auth.Status(err))
+		json.NewEncoder(w).Encode(ExperimentsResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	store, err := analyses.Default()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ExperimentsResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	if r.Method == "GET" {
+		registry, err := prompts.Default()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ExperimentsResponse{Status: "error", Error: err.Error()})
+			return
+		}
+		report, err := store.Report()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(ExperimentsResponse{Status: "error", Error: err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(ExperimentsResponse{
+			Status:      "success",
+			Experiments: registry.Experiments(),
+			Variants:    report,
+		})
+		return
+	}
+
+	var req OutcomeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ExperimentsResponse{Status: "error", Error: "Cannot parse JSON"})
+		return
+	}
+
+	outcome := analyses.Outcome{
+		Result:    req.Result,
+		ReturnPct: req.ReturnPct,
+	}
+	if err := outcome.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ExperimentsResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	record, err := store.SetOutcome(req.AnalysisID, outcome)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, analyses.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ExperimentsResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(ExperimentsResponse{Status: "success", Analysis: record})
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"stock-analysis-api/auth"
+	"stock-analysis-api/llm"
+)
+
+type LimiterResponse struct {
+	Status  string            `json:"status"`
+	Limiter *llm.LimiterStats `json:"limiter,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Limiter reports the concurrency limiter: slots in use, calls waiting, and
+// per endpoint the admitted, rejected and timed-out calls with their queue
+// times. With STORE_URL set the slot and queue counts cover every instance;
+// the per-endpoint counters always cover the current instance since it
+// started.
+func Limiter(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Admin-Token")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := auth.Admin(r); err != nil {
+		w.WriteHeader(auth.Status(err))
+		json.NewEncoder(w).Encode(LimiterResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	client, err := llm.Default()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(LimiterResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	stats, err := client.LimiterStats(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(LimiterResponse{Status: "error", Error: err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(LimiterResponse{
+		Status:  "success",
+		Limiter: &stats,
+	})
+}
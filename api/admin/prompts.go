@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"stock-analysis-api/auth"
+	"stock-analysis-api/prompts"
+)
+
+type PromptPreviewRequest struct {
+	Name string `json:"name"`
+	// Version defaults to the active version.
+	Version string `json:"version,omitempty"`
+	// Data is decoded into the template's data type, e.g.
+	// prompts.AnalyzeData for "analyze".
+	Data json.RawMessage `json:"data"`
+}
+
+type PromptsResponse struct {
+	Status    string             `json:"status"`
+	Templates []prompts.Template `json:"templates,omitempty"`
+	Version   string             `json:"version,omitempty"`
+	Preview   string             `json:"preview,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// Prompts lists the prompt templates on GET and renders a preview on POST
+// without calling the model.
+func Prompts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Admin-Token")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := auth.Admin(r); err != nil {
+		w.WriteHeader(auth.Status(err))
+		json.NewEncoder(w).Encode(PromptsResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	registry, err := prompts.Default()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(PromptsResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	if r.Method == "GET" {
+		json.NewEncoder(w).Encode(PromptsResponse{
+			Status:    "success",
+			Templates: registry.List(),
+		})
+		return
+	}
+
+	var req PromptPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PromptsResponse{Status: "error", Error: "Cannot parse JSON"})
+		return
+	}
+
+	data, err := prompts.NewData(req.Name)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(PromptsResponse{Status: "error", Error: err.Error()})
+		return
+	}
+	if len(req.Data) > 0 {
+		if err := json.Unmarshal(req.Data, data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(PromptsResponse{Status: "error", Error: "Cannot parse data: " + err.Error()})
+			return
+		}
+	}
+
+	rendered, err := registry.Render(req.Name, req.Version, data)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PromptsResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(PromptsResponse{
+		Status:  "success",
+		Version: rendered.Version,
+		Preview: rendered.Text,
+	})
+}
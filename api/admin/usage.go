@@ -0,0 +1,79 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"stock-analysis-api/auth"
+	"stock-analysis-api/llm"
+)
+
+type UsageResponse struct {
+	Status string         `json:"status"`
+	Day    string         `json:"day,omitempty"`
+	Rows   []llm.UsageRow `json:"rows"`
+	Totals llm.Usage      `json:"totals"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// Usage reports token usage and estimated cost per endpoint, model and day
+// (WIB). "?day=2006-01-02" limits the report to one day. With STORE_URL set
+// the counters are shared by every instance; without it they live in memory
+// and cover only the current process since it started.
+func Usage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Admin-Token")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := auth.Admin(r); err != nil {
+		w.WriteHeader(auth.Status(err))
+		json.NewEncoder(w).Encode(UsageResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	client, err := llm.Default()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(UsageResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	day := r.URL.Query().Get("day")
+	if day != "" {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(UsageResponse{Status: "error", Error: "day must be formatted as YYYY-MM-DD"})
+			return
+		}
+	}
+	rows, err := client.Usage().Rows(r.Context(), day)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(UsageResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	var totals llm.Usage
+	for _, row := range rows {
+		totals.Add(row.Usage)
+	}
+
+	json.NewEncoder(w).Encode(UsageResponse{
+		Status: "success",
+		Day:    day,
+		Rows:   rows,
+		Totals: totals,
+	})
+}
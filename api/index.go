@@ -28,8 +28,15 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		"endpoints": map[string]string{
 			"daily_recommendations": "GET /api/stock/daily-recommendations",
 			"analyze_stock":         "POST /api/stock/analyze",
+			"position_size":         "POST /api/stock/position-size",
+			"price_limits":          "GET /api/stock/price-limits",
 			"general_ai":            "POST /api/prompt",
+			"sessions":              "GET|POST|DELETE /api/sessions",
 			"health":                "GET /api/health",
+			"admin_usage":           "GET /api/admin/usage",
+			"admin_limiter":         "GET /api/admin/limiter",
+			"admin_prompts":         "GET|POST /api/admin/prompts",
+			"admin_experiments":     "GET|POST /api/admin/experiments",
 		},
 	}
 
@@ -1,37 +1,23 @@
 package api
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"os"
-	"time"
-)
-
-// Structs untuk Gemini API
-type GeminiRequest struct {
-	Contents []Content `json:"contents"`
-}
-
-type Content struct {
-	Parts []Part `json:"parts"`
-}
-
-type Part struct {
-	Text string `json:"text"`
-}
-
-type GeminiResponse struct {
-	Candidates []Candidate `json:"candidates"`
-}
+	"strconv"
 
-type Candidate struct {
-	Content Content `json:"content"`
-}
+	"stock-analysis-api/chat"
+	"stock-analysis-api/llm"
+	"stock-analysis-api/sse"
+)
 
 type UserPromptRequest struct {
 	Prompt string `json:"prompt"`
+	// SessionID continues a conversation created via /api/sessions. The
+	// stored history is sent along with Prompt and the reply is appended.
+	SessionID string `json:"session_id,omitempty"`
+	// Generation optionally overrides the endpoint's generation defaults
+	// within the server-side limits.
+	Generation *llm.GenerationParams `json:"generation,omitempty"`
 }
 
 func Prompt(w http.ResponseWriter, r *http.Request) {
@@ -64,69 +50,167 @@ func Prompt(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response, err := callGeminiAPI(userReq.Prompt)
+	client, err := llm.Default()
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":   "success",
-		"response": response,
-	})
-}
+	generation, err := client.ResolveGeneration("prompt", userReq.Generation)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":      err.Error(),
+			"error_code": llm.ErrorCode(err),
+		})
+		return
+	}
 
-// Helper function untuk memanggil Gemini API
-func callGeminiAPI(prompt string) (string, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		return "", fmt.Errorf("GEMINI_API_KEY is not set")
+	contents := llm.UserText(userReq.Prompt)
+
+	var session *chat.Session
+	budget := 0
+	if userReq.SessionID != "" {
+		store, err := chat.Default()
+		if err == nil {
+			session, err = store.Get(userReq.SessionID)
+		}
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+
+		budget = historyBudget(client.ContextWindow("prompt"), generation.MaxOutputTokens)
+		if budget <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "the model's context window leaves no room for the conversation history",
+			})
+			return
+		}
+		history, dropped := chat.Trim(append(session.Messages, contents...), budget)
+		session.DroppedMessages += dropped
+		contents = history
 	}
 
-	geminiReqBody := GeminiRequest{
-		Contents: []Content{
-			{
-				Parts: []Part{
-					{Text: prompt},
-				},
-			},
-		},
+	llmReq := &llm.Request{
+		Endpoint:         "prompt",
+		Contents:         contents,
+		GenerationConfig: generation,
 	}
 
-	jsonBody, err := json.Marshal(geminiReqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request body: %v", err)
+	if sse.Requested(r) {
+		streamPrompt(w, r, client, llmReq, session, budget)
+		return
 	}
 
-	url := "https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-flash:generateContent?key=" + apiKey
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	resp, err := client.Generate(r.Context(), llmReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to create HTTP request: %v", err)
+		body := map[string]interface{}{
+			"error":      err.Error(),
+			"error_code": llm.ErrorCode(err),
+		}
+		if retryAfter := llm.RetryAfterSeconds(err); retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			body["retry_after"] = retryAfter
+		}
+		w.WriteHeader(llm.HTTPStatus(err))
+		json.NewEncoder(w).Encode(body)
+		return
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request to Gemini API: %v", err)
+	json.NewEncoder(w).Encode(promptResult(llmReq, resp, session, budget))
+}
+
+// defaultMaxOutputTokens is reserved for the reply when the endpoint sets
+// no explicit output limit.
+const defaultMaxOutputTokens = 8192
+
+// historyBudget is how many tokens of conversation history fit in window
+// after leaving room for the reply. The reservation is capped at a quarter
+// of the window, so a small local model or an output limit as large as the
+// window still keeps most of the conversation.
+func historyBudget(window int, maxOutput *int) int {
+	reserve := defaultMaxOutputTokens
+	if maxOutput != nil {
+		reserve = *maxOutput
 	}
-	defer resp.Body.Close()
+	if reserve > window/4 {
+		reserve = window / 4
+	}
+	return window - reserve
+}
 
-	if resp.StatusCode != http.StatusOK {
-		var errorResponse map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&errorResponse)
-		return "", fmt.Errorf("error from Gemini API (status %d): %v", resp.StatusCode, errorResponse)
+// promptResult builds the success body. For a session it first appends the
+// new exchange to the stored history, trimmed to budget; a failed save is
+// reported but does not hide the answer. The exchange is applied to the
+// stored session rather than the copy read before the call, so prompts
+// that ran concurrently on the session are kept.
+func promptResult(llmReq *llm.Request, resp *llm.Response, session *chat.Session, budget int) map[string]interface{} {
+	body := map[string]interface{}{
+		"status":         "success",
+		"response":       resp.Text,
+		"model":          resp.Model,
+		"safety_profile": resp.SafetyProfile,
+		"persona":        resp.Persona,
+		"usage":          resp.Usage,
+		"finish_reason":  resp.FinishReason,
+		"truncated":      resp.Truncated,
 	}
 
-	var geminiResp GeminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-		return "", fmt.Errorf("failed to parse Gemini response: %v", err)
+	if session != nil {
+		// Trim keeps the newest turn, so the prompt is the last message
+		// sent.
+		exchange := []llm.Content{
+			llmReq.Contents[len(llmReq.Contents)-1],
+			{Role: llm.RoleModel, Parts: []llm.Part{{Text: resp.Text}}},
+		}
+		body["session_id"] = session.ID
+		body["dropped_messages"] = session.DroppedMessages
+		store, err := chat.Default()
+		if err == nil {
+			var saved *chat.Session
+			saved, err = store.Update(session.ID, func(s *chat.Session) {
+				history, dropped := chat.Trim(append(s.Messages, exchange...), budget)
+				s.Messages = history
+				s.DroppedMessages += dropped
+			})
+			if err == nil {
+				body["dropped_messages"] = saved.DroppedMessages
+			}
+		}
+		if err != nil {
+			body["session_error"] = err.Error()
+		}
 	}
+	return body
+}
 
-	if len(geminiResp.Candidates) > 0 && len(geminiResp.Candidates[0].Content.Parts) > 0 {
-		return geminiResp.Candidates[0].Content.Parts[0].Text, nil
+// streamPrompt sends the answer as "chunk" events followed by a "done" event
+// carrying the same body as the non-streaming response.
+func streamPrompt(w http.ResponseWriter, r *http.Request, client *llm.Client, llmReq *llm.Request, session *chat.Session, budget int) {
+	stream, err := sse.NewWriter(w)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	resp, err := client.Stream(r.Context(), llmReq, func(text string) error {
+		return stream.Send("chunk", map[string]string{"text": text})
+	})
+	if err != nil {
+		body := map[string]interface{}{
+			"error":      err.Error(),
+			"error_code": llm.ErrorCode(err),
+		}
+		if retryAfter := llm.RetryAfterSeconds(err); retryAfter > 0 {
+			body["retry_after"] = retryAfter
+		}
+		stream.Send("error", body)
+		return
 	}
 
-	return "", fmt.Errorf("no content received from Gemini")
+	stream.Send("done", promptResult(llmReq, resp, session, budget))
 }
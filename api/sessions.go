@@ -0,0 +1,112 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"stock-analysis-api/chat"
+)
+
+type CreateSessionRequest struct {
+	Title string `json:"title"`
+}
+
+// Sessions manages /api/prompt conversations:
+//
+//	POST   /api/sessions          create a session
+//	GET    /api/sessions          list sessions
+//	GET    /api/sessions?id=...   one session with its messages
+//	DELETE /api/sessions?id=...   delete a session
+func Sessions(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	store, err := chat.Default()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	id := r.URL.Query().Get("id")
+
+	switch r.Method {
+	case "POST":
+		var req CreateSessionRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Cannot parse JSON"})
+				return
+			}
+		}
+
+		session, err := store.Create(req.Title)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "success",
+			"session": session,
+		})
+
+	case "GET":
+		if id != "" {
+			session, err := store.Get(id)
+			if err != nil {
+				writeSessionError(w, err)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":  "success",
+				"session": session,
+			})
+			return
+		}
+
+		sessions, err := store.List()
+		if err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "success",
+			"sessions": sessions,
+		})
+
+	case "DELETE":
+		if id == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Session id cannot be empty"})
+			return
+		}
+		if err := store.Delete(id); err != nil {
+			writeSessionError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeSessionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, chat.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
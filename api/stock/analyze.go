@@ -1,23 +1,91 @@
 package stock
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"stock-analysis-api/analyses"
+	"stock-analysis-api/cache"
+	"stock-analysis-api/ensemble"
+	"stock-analysis-api/idx"
+	"stock-analysis-api/llm"
+	"stock-analysis-api/market"
+	"stock-analysis-api/prompts"
+	"stock-analysis-api/sse"
+	"stock-analysis-api/tradeplan"
 )
 
 type StockAnalysisRequest struct {
 	StockCode string `json:"stock_code"`
+	// Generation optionally overrides the endpoint's generation defaults
+	// within the server-side limits.
+	Generation *llm.GenerationParams `json:"generation,omitempty"`
+	// Ensemble, when set, samples several models and votes on the
+	// decision. It cannot be combined with streaming.
+	Ensemble *ensemble.Params `json:"ensemble,omitempty"`
 }
 
 type StockRecommendationResponse struct {
 	Status   string `json:"status"`
 	Date     string `json:"date"`
 	Analysis string `json:"analysis"`
-	Error    string `json:"error,omitempty"`
+	// Model is the model that actually produced Analysis, which may be a
+	// fallback from the configured chain.
+	Model string `json:"model,omitempty"`
+	// SafetyProfile is the safety-settings profile the model ran under.
+	SafetyProfile string `json:"safety_profile,omitempty"`
+	// Persona is the system instruction the model ran under.
+	Persona string `json:"persona,omitempty"`
+	// Usage is the token count and estimated cost of producing Analysis.
+	Usage *llm.Usage `json:"usage,omitempty"`
+	// FinishReason is why the model stopped. Truncated is set when it
+	// still hit the token limit after the automatic continuations.
+	FinishReason string `json:"finish_reason,omitempty"`
+	Truncated    bool   `json:"truncated,omitempty"`
+	// PromptVersion identifies the prompt template, e.g. "analyze/v1".
+	// Experiment names the prompt experiment that chose it, if any.
+	PromptVersion string `json:"prompt_version,omitempty"`
+	Experiment    string `json:"experiment,omitempty"`
+	// AnalysisID identifies the stored analysis, for recording its
+	// outcome through /api/admin/experiments. It is empty when Analysis
+	// was served from the cache, since the original was stored when it
+	// was generated.
+	AnalysisID string `json:"analysis_id,omitempty"`
+	// Cached is set when Analysis was served from the response cache.
+	Cached bool `json:"cached,omitempty"`
+	// ToolCalls lists the market data lookups the model made.
+	ToolCalls []llm.ToolCall `json:"tool_calls,omitempty"`
+	// TradePlan is the recommendation in Analysis as typed fields. When it
+	// could not be produced, TradePlanError says why and Analysis is still
+	// returned. TradePlanSource is "markdown" when the plan was parsed from
+	// Analysis because the structuring call failed.
+	TradePlan       *tradeplan.TradePlan `json:"trade_plan,omitempty"`
+	TradePlanError  string               `json:"trade_plan_error,omitempty"`
+	TradePlanSource string               `json:"trade_plan_source,omitempty"`
+	// TradePlanWarnings lists the levels of TradePlan that broke the
+	// trader profile and were repaired by the server.
+	TradePlanWarnings []tradeplan.Warning `json:"trade_plan_warnings,omitempty"`
+	// DailyPicks is the structured form of the daily recommendations, set
+	// only by DailyRecommendations. DailyPicksError works like
+	// TradePlanError.
+	DailyPicks      *tradeplan.DailyPicks `json:"daily_picks,omitempty"`
+	DailyPicksError string                `json:"daily_picks_error,omitempty"`
+	// Consensus reports the ensemble vote when the request asked for one.
+	// Analysis and TradePlan then come from a sample of the majority.
+	Consensus *ensemble.Result `json:"consensus,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	// ErrorCode classifies Error, e.g. PROMPT_BLOCKED or
+	// UPSTREAM_RATE_LIMITED.
+	ErrorCode string `json:"error_code,omitempty"`
+	// RetryAfter is set in seconds when the upstream model is temporarily
+	// unavailable and the request may be repeated.
+	RetryAfter int `json:"retry_after,omitempty"`
 }
 
 func Analyze(w http.ResponseWriter, r *http.Request) {
@@ -50,75 +118,94 @@ func Analyze(w http.ResponseWriter, r *http.Request) {
 	}
 
 	currentDate := time.Now().Format("2006-01-02")
-	stockContext := getStockContext(req.StockCode)
-
-	prompt := fmt.Sprintf(`Anda adalah senior portfolio manager dari investment firm terkemuka di Jakarta dengan akses ke Bloomberg terminal dan data real-time. Klien Anda meminta analisis trading untuk saham %s pada %s.
-
-%s
-
-IMPORTANT CONTEXT: Ini adalah analisis internal untuk klien professional dengan modal Rp 7.5 juta. Berikan analisis praktis dan actionable.
-
-TRADING PROFILE:
-- Capital: Rp 7,500,000
-- Target: 4-5%% profit per trade  
-- Style: Active day/swing trading
-- Risk tolerance: Medium-aggressive
-
-ANALISIS PROFESIONAL UNTUK %s:
 
-**STOCK DATA CURRENT**
-- Company: %s
-- Sector: [Based on your knowledge]
-- Current price: Rp [Provide realistic estimate]
-- Daily volume: [Typical volume for this stock]
-- Market cap: [Calculate based on shares outstanding]
-
-**TECHNICAL ANALYSIS**
-- Trend: [Current short-term trend]
-- Support levels: Rp [2 key levels]
-- Resistance levels: Rp [2 key levels]  
-- RSI (14): [Estimate current level]
-- MACD status: [Above/below signal line]
-- Volume pattern: [Recent volume vs average]
-
-**FUNDAMENTAL SNAPSHOT**
-- Recent earnings: [Latest quarter performance]
-- Revenue growth: [YoY growth rate]
-- Industry outlook: [Sector conditions]
-- Key catalysts: [Upcoming events/news]
-
-**TRADING RECOMMENDATION**
+	// Persona, trading profile and output rules come from the endpoint's
+	// system instruction; the template only carries the task.
+	prompt, err := prompts.Analyze(prompts.ClientID(r), prompts.AnalyzeData{
+		StockCode:    req.StockCode,
+		Date:         currentDate,
+		CompanyName:  market.CompanyName(req.StockCode),
+		StockContext: market.StockContext(req.StockCode),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(StockRecommendationResponse{
+			Status: "error",
+			Error:  err.Error(),
+		})
+		return
+	}
 
-Entry Decision: [BUY/HOLD/AVOID]
+	client, err := llm.Default()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(StockRecommendationResponse{
+			Status: "error",
+			Error:  err.Error(),
+		})
+		return
+	}
 
-If BUY:
-- Entry zone: Rp [specific range]
-- Target 1 (4%%): Rp [exact price]
-- Target 2 (5%%): Rp [exact price] 
-- Stop loss: Rp [price level]
-- Position size: Rp [amount from 7.5M]
-- Timeline: [1-3 days]
+	generation, err := client.ResolveGeneration("analyze", req.Generation)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(StockRecommendationResponse{
+			Status:    "error",
+			Error:     err.Error(),
+			ErrorCode: llm.ErrorCode(err),
+		})
+		return
+	}
 
-If AVOID:
-- Reason: [Specific issues]
-- Wait for: [Better conditions]
-- Alternative: [Better stock picks]
+	llmReq := &llm.Request{
+		Endpoint:         "analyze",
+		Contents:         llm.UserText(prompt.Text),
+		GenerationConfig: generation,
+		Refresh:          cache.Refresh(r),
+	}
 
-**RISK FACTORS**
-- Volatility: [High/Medium/Low]
-- Liquidity: [Easy/Difficult to exit]
-- Market correlation: [Beta estimate]
+	if req.Ensemble != nil {
+		if sse.Requested(r) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(StockRecommendationResponse{
+				Status: "error",
+				Error:  "ensemble mode does not support streaming",
+			})
+			return
+		}
+		ensembleAnalysis(w, r, client, llmReq, req.Ensemble, req.StockCode, prompt, currentDate)
+		return
+	}
 
-**EXECUTION PLAN**
-- Best entry time: [Market hours preference]
-- Order type: [Market/Limit recommendation]
-- Monitoring: [Key levels to watch]
+	if sse.Requested(r) {
+		streamAnalysis(w, r, client, llmReq, req.StockCode, prompt, currentDate)
+		return
+	}
 
-Confidence: [1-10] with rationale
+	resp, err := client.GenerateWithTools(r.Context(), llmReq, market.Tools(market.DefaultSource()))
+	if err != nil {
+		retryAfter := llm.RetryAfterSeconds(err)
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
+		w.WriteHeader(llm.HTTPStatus(err))
+		json.NewEncoder(w).Encode(StockRecommendationResponse{
+			Status:     "error",
+			Error:      err.Error(),
+			ErrorCode:  llm.ErrorCode(err),
+			RetryAfter: retryAfter,
+		})
+		return
+	}
 
-Provide practical, actionable analysis based on current market knowledge for Indonesian stocks. Focus on realistic price levels and executable strategy for Rp 7.5M capital.`, req.StockCode, currentDate, stockContext, req.StockCode, getCompanyName(req.StockCode))
+	cache.SetHeaders(w, resp.Cached, resp.CacheExpires)
+	json.NewEncoder(w).Encode(analysisResult(r.Context(), client, resp, req.StockCode, prompt, currentDate))
+}
 
-	response, err := callGemini2API(prompt)
+// streamAnalysis forwards the analysis as "chunk" events while the model is
+// writing it, then sends the complete StockRecommendationResponse as "done".
+func streamAnalysis(w http.ResponseWriter, r *http.Request, client *llm.Client, llmReq *llm.Request, stockCode string, prompt prompts.Rendered, currentDate string) {
+	stream, err := sse.NewWriter(w)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(StockRecommendationResponse{
@@ -128,152 +215,177 @@ Provide practical, actionable analysis based on current market knowledge for Ind
 		return
 	}
 
-	json.NewEncoder(w).Encode(StockRecommendationResponse{
-		Status:   "success",
-		Date:     currentDate,
-		Analysis: response,
+	resp, err := client.StreamWithTools(r.Context(), llmReq, market.Tools(market.DefaultSource()), func(text string) error {
+		return stream.Send("chunk", map[string]string{"text": text})
 	})
-}
-
-func getCompanyName(stockCode string) string {
-	companies := map[string]string{
-		"CDIA": "PT Chandra Daya Investasi Tbk",
-		"GOTO": "PT GoTo Gojek Tokopedia Tbk",
-		"BBCA": "PT Bank Central Asia Tbk",
-		"BBRI": "PT Bank Rakyat Indonesia Tbk",
-		"BMRI": "PT Bank Mandiri Tbk",
-		"ASII": "PT Astra International Tbk",
-		"UNVR": "PT Unilever Indonesia Tbk",
-		"TLKM": "PT Telkom Indonesia Tbk",
-		"COIN": "PT Digital Coin Indonesia Tbk",
-		"CUAN": "PT Arha Capital Tbk",
+	if err != nil {
+		stream.Send("error", StockRecommendationResponse{
+			Status:     "error",
+			Error:      err.Error(),
+			ErrorCode:  llm.ErrorCode(err),
+			RetryAfter: llm.RetryAfterSeconds(err),
+		})
+		return
 	}
 
-	if name, exists := companies[stockCode]; exists {
-		return name
-	}
-	return fmt.Sprintf("PT %s Tbk", stockCode)
+	stream.Send("done", analysisResult(r.Context(), client, resp, stockCode, prompt, currentDate))
 }
 
-func getStockContext(stockCode string) string {
-	switch stockCode {
-	case "CDIA":
-		return `CURRENT MARKET DATA (Chandra Daya Investasi):
-Recent IPO with strong post-listing performance. Infrastructure/energy sector play, subsidiary of TPIA (Chandra Asri). Price range: 1,400-1,700 area based on recent trading. High volatility post-IPO typical. Multiple auto rejection atas (ARA) events. Strong fundamental backing from parent company. High retail interest. Trading volume varies significantly.`
-
-	case "GOTO":
-		return `CURRENT MARKET DATA (GoTo Gojek Tokopedia):
-Established tech stock, large cap with high liquidity. Super app ecosystem business model. Typical trading range 100-150 based on historical patterns. Medium volatility suitable for swing trading. High daily volume, easy entry/exit. Focus on path to profitability, strong user metrics.`
-
-	case "BBCA":
-		return `CURRENT MARKET DATA (Bank Central Asia):
-Premium Indonesian bank, highest quality banking stock. Typical range 8,000-10,000 based on historical. Low-medium volatility. Excellent liquidity. Consistent dividend payer. Strong digital banking. Defensive play with quality fundamentals.`
-
-	case "BBRI":
-		return `CURRENT MARKET DATA (Bank Rakyat Indonesia):
-Large government-related bank, strong SME/rural network. Typical range 4,000-5,500. Low-medium volatility. High liquidity. Government backing provides stability. Solid dividend history.`
-
-	case "CUAN":
-		return `CURRENT MARKET DATA (Arha Capital):
-Digital asset/crypto-related investment company. High volatility correlated with crypto markets. Speculative stock with high beta. Suitable for aggressive momentum traders.`
+// ensembleAnalysis runs the analysis through every ensemble member and
+// answers with the majority sample plus the vote.
+func ensembleAnalysis(w http.ResponseWriter, r *http.Request, client *llm.Client, llmReq *llm.Request, params *ensemble.Params, stockCode string, prompt prompts.Rendered, currentDate string) {
+	// A member that cannot be built is a server configuration error; only
+	// the sample count comes from the request.
+	if _, err := client.EnsembleMembers(); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(StockRecommendationResponse{
+			Status: "error",
+			Error:  err.Error(),
+		})
+		return
+	}
+	samples, err := ensemble.SampleCount(client, params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(StockRecommendationResponse{
+			Status: "error",
+			Error:  err.Error(),
+		})
+		return
+	}
 
-	case "COIN":
-		return `CURRENT MARKET DATA (Digital Coin):
-Crypto-related business model. Extreme volatility following crypto market sentiment. High risk, high reward potential. Momentum-driven trading.`
+	result, err := ensemble.Run(r.Context(), client, llmReq, market.Tools(market.DefaultSource()), samples)
+	if err != nil {
+		retryAfter := llm.RetryAfterSeconds(err)
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
+		w.WriteHeader(llm.HTTPStatus(err))
+		json.NewEncoder(w).Encode(StockRecommendationResponse{
+			Status:     "error",
+			Error:      err.Error(),
+			ErrorCode:  llm.ErrorCode(err),
+			RetryAfter: retryAfter,
+		})
+		return
+	}
 
-	default:
-		return `MARKET DATA: Analyze based on sector characteristics and provide realistic price estimates for Indonesian market conditions.`
+	resp := result.Response
+	response := StockRecommendationResponse{
+		Status:        "success",
+		Date:          currentDate,
+		Analysis:      resp.Text,
+		Model:         resp.Model,
+		SafetyProfile: resp.SafetyProfile,
+		Persona:       resp.Persona,
+		PromptVersion: prompt.Version,
+		Experiment:    prompt.Experiment,
+		Usage:         &result.Usage,
+		FinishReason:  resp.FinishReason,
+		Truncated:     resp.Truncated,
+		ToolCalls:     resp.ToolCalls,
+		Consensus:     result,
 	}
+	setTradePlan(r.Context(), &response, result.Plan, stockCode)
+	saveAnalysis(&response, stockCode)
+	json.NewEncoder(w).Encode(response)
 }
 
-func callGemini2API(prompt string) (string, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		return "", fmt.Errorf("GEMINI_API_KEY is not set")
+// analysisResult builds the success response, adds the TradePlan
+// structured from the narrative and stores the analysis. Usage covers both
+// calls.
+func analysisResult(ctx context.Context, client *llm.Client, resp *llm.Response, stockCode string, prompt prompts.Rendered, currentDate string) StockRecommendationResponse {
+	result := StockRecommendationResponse{
+		Status:        "success",
+		Date:          currentDate,
+		Analysis:      resp.Text,
+		Model:         resp.Model,
+		SafetyProfile: resp.SafetyProfile,
+		Persona:       resp.Persona,
+		PromptVersion: prompt.Version,
+		Experiment:    prompt.Experiment,
+		Usage:         &resp.Usage,
+		FinishReason:  resp.FinishReason,
+		Truncated:     resp.Truncated,
+		Cached:        resp.Cached,
+		ToolCalls:     resp.ToolCalls,
 	}
 
-	requestBody := map[string]interface{}{
-		"contents": []map[string]interface{}{
-			{
-				"parts": []map[string]interface{}{
-					{
-						"text": prompt,
-					},
-				},
-			},
-		},
-		"generationConfig": map[string]interface{}{
-			"temperature":     0.9, // Higher creativity for realistic estimates
-			"topK":            40,
-			"topP":            0.95,
-			"maxOutputTokens": 8192,
-		},
-		"safetySettings": []map[string]interface{}{
-			{
-				"category":  "HARM_CATEGORY_DANGEROUS_CONTENT",
-				"threshold": "BLOCK_NONE",
-			},
-			{
-				"category":  "HARM_CATEGORY_HARASSMENT",
-				"threshold": "BLOCK_NONE",
-			},
-			{
-				"category":  "HARM_CATEGORY_HATE_SPEECH",
-				"threshold": "BLOCK_NONE",
-			},
-			{
-				"category":  "HARM_CATEGORY_SEXUALLY_EXPLICIT",
-				"threshold": "BLOCK_NONE",
-			},
-		},
+	plan, planResp, err := tradeplan.Structure(ctx, client, resp.Text)
+	if planResp != nil {
+		result.Usage.Add(planResp.Usage)
 	}
-
-	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request body: %v", err)
+		// The markdown section still carries the plan when the model could
+		// not transcribe it.
+		ext := tradeplan.Extract(resp.Text)
+		if !ext.Complete() {
+			result.TradePlanError = fmt.Sprintf("%v; markdown fallback is missing %s", err, strings.Join(ext.Missing, ", "))
+			saveAnalysis(&result, stockCode)
+			return result
+		}
+		plan = &ext.Plan
+		result.TradePlanSource = "markdown"
 	}
+	setTradePlan(ctx, &result, plan, stockCode)
+	saveAnalysis(&result, stockCode)
+	return result
+}
 
-	url := "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.0-flash:generateContent"
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create HTTP request: %v", err)
+// setTradePlan repairs plan against the trader profile, rounds it to IDX
+// ticks, sizes it in whole lots, checks it against the session's price
+// limits and sets it with the warnings on result.
+func setTradePlan(ctx context.Context, result *StockRecommendationResponse, plan *tradeplan.TradePlan, stockCode string) {
+	plan.RoundToTicks()
+	result.TradePlan, result.TradePlanWarnings = tradeplan.Repair(plan, tradeplan.Capital)
+	result.TradePlan.SizeLots()
+	if limits, ok := sessionLimits(ctx, stockCode); ok {
+		result.TradePlan.CheckLimits(limits)
 	}
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-goog-api-key", apiKey)
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+// sessionLimits returns the ARA/ARB limits of stockCode from the previous
+// close and board in the market data feed. When the feed does not report
+// the board the main board is assumed and Limits.BoardAssumed says so.
+// Without a feed it returns false and the levels are left unchecked.
+func sessionLimits(ctx context.Context, stockCode string) (idx.Limits, bool) {
+	quote, err := market.DefaultSource().Quote(ctx, stockCode)
+	if err != nil || quote.PrevClose <= 0 {
+		return idx.Limits{}, false
+	}
+	limits, err := idx.AutoRejection(quote.PrevClose, quote.Board, "")
 	if err != nil {
-		return "", fmt.Errorf("failed to send request to Gemini 2.0 API: %v", err)
+		return idx.Limits{}, false
 	}
-	defer resp.Body.Close()
+	return limits, true
+}
 
-	if resp.StatusCode != http.StatusOK {
-		var errorResponse map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&errorResponse)
-		return "", fmt.Errorf("error from Gemini 2.0 API (status %d): %v", resp.StatusCode, errorResponse)
+// saveAnalysis stores result with its prompt variant and sets AnalysisID.
+// Cached results are skipped so that repeated requests do not add
+// duplicate records. A failure only loses the record, so it is logged and
+// the analysis is still returned.
+func saveAnalysis(result *StockRecommendationResponse, stockCode string) {
+	if result.Cached {
+		return
 	}
-
-	var geminiResp map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-		return "", fmt.Errorf("failed to parse Gemini 2.0 response: %v", err)
+	record := &analyses.Record{
+		StockCode:     stockCode,
+		Date:          result.Date,
+		PromptVersion: result.PromptVersion,
+		Experiment:    result.Experiment,
+		Model:         result.Model,
+		TradePlan:     result.TradePlan,
 	}
-
-	if candidates, ok := geminiResp["candidates"].([]interface{}); ok && len(candidates) > 0 {
-		if candidate, ok := candidates[0].(map[string]interface{}); ok {
-			if content, ok := candidate["content"].(map[string]interface{}); ok {
-				if parts, ok := content["parts"].([]interface{}); ok && len(parts) > 0 {
-					if part, ok := parts[0].(map[string]interface{}); ok {
-						if text, ok := part["text"].(string); ok {
-							return text, nil
-						}
-					}
-				}
-			}
-		}
+	if result.TradePlan != nil {
+		record.Decision = result.TradePlan.Decision
 	}
-
-	return "", fmt.Errorf("no content received from Gemini 2.0")
+	store, err := analyses.Default()
+	if err == nil {
+		err = store.Save(record)
+	}
+	if err != nil {
+		log.Printf("failed to store analysis: %v", err)
+		return
+	}
+	result.AnalysisID = record.ID
 }
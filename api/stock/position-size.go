@@ -0,0 +1,73 @@
+package stock
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"stock-analysis-api/idx"
+	"stock-analysis-api/tradeplan"
+)
+
+type PositionSizeRequest struct {
+	// Price is the entry price; it is rounded down to a valid IDX tick.
+	Price float64 `json:"price"`
+	// Amount is the rupiah budget. When it is zero the budget is
+	// AllocationPct of Capital.
+	Amount float64 `json:"amount,omitempty"`
+	// Capital defaults to the trading profile's Rp 7.5M and AllocationPct
+	// to the 30% single-position maximum.
+	Capital       float64 `json:"capital,omitempty"`
+	AllocationPct float64 `json:"allocation_pct,omitempty"`
+}
+
+type PositionSizeResponse struct {
+	Status   string        `json:"status"`
+	Position *idx.Position `json:"position,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// PositionSize converts a budget and entry price into whole IDX lots
+// without calling the model.
+func PositionSize(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PositionSizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PositionSizeResponse{Status: "error", Error: "Cannot parse JSON"})
+		return
+	}
+
+	if req.Capital == 0 {
+		req.Capital = tradeplan.Capital
+	}
+	if req.AllocationPct == 0 {
+		req.AllocationPct = tradeplan.MaxPositionPct
+	}
+	budget := req.Amount
+	if budget == 0 {
+		budget = req.Capital * req.AllocationPct / 100
+	}
+
+	position, err := idx.Size(budget, idx.RoundDown(req.Price))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PositionSizeResponse{Status: "error", Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(PositionSizeResponse{Status: "success", Position: &position})
+}
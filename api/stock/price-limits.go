@@ -0,0 +1,83 @@
+package stock
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"stock-analysis-api/idx"
+	"stock-analysis-api/market"
+)
+
+type PriceLimitsResponse struct {
+	Status    string      `json:"status"`
+	StockCode string      `json:"stock_code,omitempty"`
+	Limits    *idx.Limits `json:"limits,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// PriceLimits returns the ARA/ARB limits of a stock for one session. The
+// previous close comes from ?prev_close= or, without it, from the market
+// data feed; ?board= and ?arb_mode= default to the main board and
+// IDX_ARB_MODE.
+func PriceLimits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	stockCode := strings.ToUpper(q.Get("stock_code"))
+
+	board := q.Get("board")
+	var prevClose float64
+	if v := q.Get("prev_close"); v != "" {
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(PriceLimitsResponse{Status: "error", Error: "prev_close must be a number"})
+			return
+		}
+		prevClose = parsed
+	} else {
+		if stockCode == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(PriceLimitsResponse{Status: "error", Error: "stock_code or prev_close is required"})
+			return
+		}
+		quote, err := market.DefaultSource().Quote(r.Context(), stockCode)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(PriceLimitsResponse{Status: "error", StockCode: stockCode, Error: err.Error()})
+			return
+		}
+		prevClose = quote.PrevClose
+		if board == "" {
+			board = quote.Board
+		}
+	}
+
+	limits, err := idx.AutoRejection(prevClose, board, q.Get("arb_mode"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(PriceLimitsResponse{Status: "error", StockCode: stockCode, Error: err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(PriceLimitsResponse{
+		Status:    "success",
+		StockCode: stockCode,
+		Limits:    &limits,
+	})
+}
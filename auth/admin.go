@@ -0,0 +1,41 @@
+// Package auth guards the admin endpoints.
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	ErrAdminDisabled = errors.New("admin endpoints are disabled: ADMIN_TOKEN is not set")
+	ErrUnauthorized  = errors.New("invalid or missing admin token")
+)
+
+// Admin checks the request for the ADMIN_TOKEN, sent either as
+// "Authorization: Bearer <token>" or as "X-Admin-Token: <token>".
+func Admin(r *http.Request) error {
+	want := os.Getenv("ADMIN_TOKEN")
+	if want == "" {
+		return ErrAdminDisabled
+	}
+
+	got := r.Header.Get("X-Admin-Token")
+	if bearer := r.Header.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		got = strings.TrimPrefix(bearer, "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// Status maps an error from Admin to an HTTP status code.
+func Status(err error) int {
+	if errors.Is(err, ErrAdminDisabled) {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
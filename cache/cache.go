@@ -0,0 +1,81 @@
+// Package cache stores generated responses between requests. The in-memory
+// LRU works per instance; Redis shares entries across instances.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Cache is a byte store with per-entry expiry.
+type Cache interface {
+	// Get returns the value and true on a hit.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Key hashes the given parts into a fixed-length key.
+func Key(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// New builds the backend named by rawURL: "memory" or "memory://?size=N"
+// for an LRU, "redis://[:password@]host:port[/db]" for Redis and "none" to
+// disable caching. The size query parameter overrides size.
+func New(rawURL string, size int) (Cache, error) {
+	switch rawURL {
+	case "", "memory":
+		return NewLRU(size), nil
+	case "none":
+		return nil, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache URL: %v", err)
+	}
+	switch u.Scheme {
+	case "memory":
+		if v := u.Query().Get("size"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid cache size %q", v)
+			}
+			size = n
+		}
+		return NewLRU(size), nil
+	case "redis":
+		return NewRedis(u)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", u.Scheme)
+	}
+}
+
+// Shared returns the Redis server named by STORE_URL, which holds state
+// that all instances must see. It returns nil when STORE_URL is unset or
+// "memory", meaning that state stays in process memory.
+func Shared() (*Redis, error) {
+	rawURL := os.Getenv("STORE_URL")
+	if rawURL == "" || rawURL == "memory" {
+		return nil, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORE_URL: %v", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("unknown store backend %q", u.Scheme)
+	}
+	return NewRedis(u)
+}
@@ -0,0 +1,44 @@
+package cache
+
+import "testing"
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantSize int // 0 means no cache
+		wantErr  bool
+	}{
+		{url: "", wantSize: 256},
+		{url: "memory", wantSize: 256},
+		{url: "memory://", wantSize: 256},
+		{url: "memory://?size=32", wantSize: 32},
+		{url: "memory://?size=0", wantErr: true},
+		{url: "memory://?size=big", wantErr: true},
+		{url: "none"},
+		{url: "memcached://localhost", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			c, err := New(tt.url, 256)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("New(%q) succeeded, want error", tt.url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New(%q): %v", tt.url, err)
+			}
+			if tt.wantSize == 0 {
+				if c != nil {
+					t.Errorf("New(%q) = %T, want no cache", tt.url, c)
+				}
+				return
+			}
+			lru, ok := c.(*LRU)
+			if !ok || lru.size != tt.wantSize {
+				t.Errorf("New(%q) = %#v, want LRU of %d", tt.url, c, tt.wantSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Refresh reports whether the client asked to bypass cached answers, via
+// ?refresh=true or a Cache-Control: no-cache request header.
+func Refresh(r *http.Request) bool {
+	return r.URL.Query().Get("refresh") == "true" ||
+		strings.Contains(r.Header.Get("Cache-Control"), "no-cache")
+}
+
+// SetHeaders reports a cached answer through X-Cache and Cache-Control.
+// It does nothing when expires is zero, i.e. the answer is not cached.
+func SetHeaders(w http.ResponseWriter, hit bool, expires time.Time) {
+	if expires.IsZero() {
+		return
+	}
+	status := "MISS"
+	if hit {
+		status = "HIT"
+	}
+	maxAge := int(time.Until(expires).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	w.Header().Set("X-Cache", status)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+}
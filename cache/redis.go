@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis is a minimal client for any server speaking the Redis protocol
+// (Redis, Valkey, KeyDB, Upstash over TCP). It opens one connection per
+// call, which suits short-lived serverless instances.
+type Redis struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+}
+
+// NewRedis parses redis://[:password@]host:port[/db].
+func NewRedis(u *url.URL) (*Redis, error) {
+	r := &Redis{addr: u.Host, timeout: 2 * time.Second}
+	if r.addr == "" {
+		return nil, fmt.Errorf("redis URL needs a host")
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+		r.db = n
+	}
+	return r, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.Do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	return reply, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.Do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Do runs one command and returns its simple, integer or bulk string reply.
+// A nil reply is returned as nil.
+func (r *Redis) Do(ctx context.Context, args ...string) ([]byte, error) {
+	reply, err := r.do(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	switch v := reply.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis array reply to %s", args[0])
+	}
+}
+
+// Strings runs one command that replies with an array, such as SMEMBERS,
+// HGETALL or MGET. Nil elements are returned as "".
+func (r *Redis) Strings(ctx context.Context, args ...string) ([]string, error) {
+	reply, err := r.do(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]any)
+	if !ok && reply != nil {
+		return nil, fmt.Errorf("unexpected redis reply to %s", args[0])
+	}
+	out := make([]string, len(items))
+	for i, item := range items {
+		b, ok := item.([]byte)
+		if !ok && item != nil {
+			return nil, fmt.Errorf("unexpected nested redis array in reply to %s", args[0])
+		}
+		out[i] = string(b)
+	}
+	return out, nil
+}
+
+// do runs AUTH and SELECT as needed, then the command, and returns the
+// reply of the command as readReply decodes it.
+func (r *Redis) do(ctx context.Context, args []string) (any, error) {
+	dialer := net.Dialer{Timeout: r.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(r.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	var cmds [][]string
+	if r.password != "" {
+		cmds = append(cmds, []string{"AUTH", r.password})
+	}
+	if r.db != 0 {
+		cmds = append(cmds, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	cmds = append(cmds, args)
+
+	w := bufio.NewWriter(conn)
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "*%d\r\n", len(cmd))
+		for _, a := range cmd {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(a), a)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to send redis command: %v", err)
+	}
+
+	rd := bufio.NewReader(conn)
+	var reply any
+	for range cmds {
+		if reply, err = readReply(rd); err != nil {
+			return nil, err
+		}
+	}
+	return reply, nil
+}
+
+// readReply reads one reply: simple, integer and bulk strings as []byte,
+// nil replies as nil and arrays as []any. Error replies are returned as
+// errors.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redis reply: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, fmt.Errorf("failed to read redis reply: %v", err)
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unsupported redis reply %q", line)
+	}
+}
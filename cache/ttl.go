@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"time"
+
+	"stock-analysis-api/idx"
+)
+
+// IDX regular market hours in WIB.
+const (
+	marketOpenHour  = 9
+	marketCloseHour = 16
+)
+
+// UntilTradingDayEnd returns how long an answer produced at now stays
+// valid. Before the close of a weekday session it expires at the close;
+// otherwise at the next weekday open. Exchange holidays are not known and
+// are treated as trading days.
+func UntilTradingDayEnd(now time.Time) time.Duration {
+	t := now.In(idx.WIB)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, idx.WIB)
+
+	if isWeekday(day) {
+		if sessionEnd := day.Add(marketCloseHour * time.Hour); t.Before(sessionEnd) {
+			return sessionEnd.Sub(t)
+		}
+	}
+
+	next := day.AddDate(0, 0, 1)
+	for !isWeekday(next) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Add(marketOpenHour * time.Hour).Sub(t)
+}
+
+func isWeekday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"stock-analysis-api/idx"
+)
+
+func TestUntilTradingDayEnd(t *testing.T) {
+	wib := func(day, hour, min int) time.Time {
+		// October 2026: the 12th is a Monday.
+		return time.Date(2026, time.October, day, hour, min, 0, 0, idx.WIB)
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Duration
+	}{
+		{name: "before open", now: wib(14, 7, 30), want: 8*time.Hour + 30*time.Minute},
+		{name: "during session", now: wib(14, 10, 15), want: 5*time.Hour + 45*time.Minute},
+		{name: "at close", now: wib(14, 16, 0), want: 17 * time.Hour},
+		{name: "after close", now: wib(14, 20, 0), want: 13 * time.Hour},
+		{name: "friday after close", now: wib(16, 17, 0), want: 64 * time.Hour},
+		{name: "saturday", now: wib(17, 12, 0), want: 45 * time.Hour},
+		{name: "sunday night", now: wib(18, 23, 0), want: 10 * time.Hour},
+		{name: "utc input", now: wib(14, 10, 15).UTC(), want: 5*time.Hour + 45*time.Minute},
+		// 23:00 UTC on Sunday is already Monday 06:00 in Jakarta.
+		{name: "utc sunday is wib monday", now: time.Date(2026, time.October, 18, 23, 0, 0, 0, time.UTC), want: 10 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UntilTradingDayEnd(tt.now); got != tt.want {
+				t.Errorf("UntilTradingDayEnd(%v) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
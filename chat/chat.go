@@ -0,0 +1,198 @@
+// Package chat keeps the message history of multi-turn /api/prompt
+// conversations.
+package chat
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"stock-analysis-api/cache"
+	"stock-analysis-api/ids"
+	"stock-analysis-api/llm"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+// Session is one conversation. Messages alternate between llm.RoleUser and
+// llm.RoleModel and always start with a user turn.
+type Session struct {
+	ID        string        `json:"id"`
+	Title     string        `json:"title,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Messages  []llm.Content `json:"messages"`
+	// DroppedMessages counts the oldest messages removed to stay within
+	// the model's context window.
+	DroppedMessages int `json:"dropped_messages,omitempty"`
+}
+
+// Summary is the listing view of a Session.
+type Summary struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	MessageCount int       `json:"message_count"`
+}
+
+// Store persists sessions. Get returns a copy; callers change a session
+// through Update, which applies fn to the current version so that
+// concurrent prompts on one session do not overwrite each other.
+type Store interface {
+	Create(title string) (*Session, error)
+	Get(id string) (*Session, error)
+	Update(id string, fn func(s *Session)) (*Session, error)
+	List() ([]Summary, error)
+	Delete(id string) error
+}
+
+// MemoryStore keeps sessions in process memory and forgets those idle for
+// longer than ttl.
+type MemoryStore struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, sessions: make(map[string]*Session)}
+}
+
+// sessionTTL is how long an idle session is kept.
+const sessionTTL = 24 * time.Hour
+
+var (
+	defaultOnce  sync.Once
+	defaultStore Store
+	defaultErr   error
+)
+
+// Default returns the process-wide store used by the API handlers: Redis
+// when STORE_URL is set, otherwise process memory.
+func Default() (Store, error) {
+	defaultOnce.Do(func() {
+		shared, err := cache.Shared()
+		switch {
+		case err != nil:
+			defaultErr = err
+		case shared != nil:
+			defaultStore = NewRedisStore(shared, sessionTTL)
+		default:
+			defaultStore = NewMemoryStore(sessionTTL)
+		}
+	})
+	return defaultStore, defaultErr
+}
+
+func (m *MemoryStore) Create(title string) (*Session, error) {
+	id, err := ids.New()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s := &Session{ID: id, Title: title, CreatedAt: now, UpdatedAt: now, Messages: []llm.Content{}}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expireLocked(now)
+	m.sessions[id] = s
+	return copySession(s), nil
+}
+
+func (m *MemoryStore) Get(id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expireLocked(time.Now())
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copySession(s), nil
+}
+
+func (m *MemoryStore) Update(id string, fn func(s *Session)) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expireLocked(time.Now())
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updated := copySession(s)
+	fn(updated)
+	updated.ID = id
+	updated.UpdatedAt = time.Now()
+	m.sessions[id] = updated
+	return copySession(updated), nil
+}
+
+func (m *MemoryStore) List() ([]Summary, error) {
+	m.mu.Lock()
+	m.expireLocked(time.Now())
+	out := make([]Summary, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		out = append(out, Summary{
+			ID:           s.ID,
+			Title:        s.Title,
+			CreatedAt:    s.CreatedAt,
+			UpdatedAt:    s.UpdatedAt,
+			MessageCount: len(s.Messages),
+		})
+	}
+	m.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out, nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemoryStore) expireLocked(now time.Time) {
+	if m.ttl <= 0 {
+		return
+	}
+	for id, s := range m.sessions {
+		if now.Sub(s.UpdatedAt) > m.ttl {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+func copySession(s *Session) *Session {
+	c := *s
+	c.Messages = make([]llm.Content, len(s.Messages))
+	copy(c.Messages, s.Messages)
+	return &c
+}
+
+// Trim drops the oldest turns of history until it fits in budget tokens,
+// as estimated by llm.EstimateTokens. Turns are dropped in user/model
+// pairs so the history still starts with a user message; the newest turn
+// is always kept. It returns the trimmed history and how many messages
+// were dropped.
+func Trim(history []llm.Content, budget int) ([]llm.Content, int) {
+	dropped := 0
+	for len(history) > 1 && llm.EstimateTokens(history) > budget {
+		n := 1
+		if len(history) > 2 && history[1].Role == llm.RoleModel {
+			n = 2
+		}
+		history = history[n:]
+		dropped += n
+	}
+	return history, dropped
+}
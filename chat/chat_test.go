@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"stock-analysis-api/cache"
+	"stock-analysis-api/llm"
+)
+
+// testUpdateConcurrent appends one message per goroutine and checks that
+// none was lost. With updateAttempts writers each Update succeeds within
+// its attempts, because every failed attempt means another writer won.
+func testUpdateConcurrent(t *testing.T, store Store) {
+	session, err := store.Create("concurrent")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, updateAttempts)
+	for i := 0; i < updateAttempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := store.Update(session.ID, func(s *Session) {
+				s.Messages = append(s.Messages, llm.UserText(fmt.Sprint(i))...)
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Update: %v", err)
+		}
+	}
+
+	got, err := store.Get(session.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(got.Messages) != updateAttempts {
+		t.Errorf("messages = %d, want %d", len(got.Messages), updateAttempts)
+	}
+	if !got.UpdatedAt.After(session.UpdatedAt) {
+		t.Errorf("UpdatedAt = %v, want after %v", got.UpdatedAt, session.UpdatedAt)
+	}
+}
+
+func TestMemoryStoreUpdate(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	testUpdateConcurrent(t, store)
+
+	if _, err := store.Update("missing", func(*Session) {}); err != ErrNotFound {
+		t.Errorf("Update of a missing session = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRedisStoreUpdate(t *testing.T) {
+	raw := os.Getenv("TEST_REDIS_URL")
+	if raw == "" {
+		t.Skip("TEST_REDIS_URL is not set")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("TEST_REDIS_URL: %v", err)
+	}
+	r, err := cache.NewRedis(u)
+	if err != nil {
+		t.Fatalf("TEST_REDIS_URL: %v", err)
+	}
+	store := NewRedisStore(r, time.Minute)
+	testUpdateConcurrent(t, store)
+
+	if _, err := store.Update("missing", func(*Session) {}); err != ErrNotFound {
+		t.Errorf("Update of a missing session = %v, want ErrNotFound", err)
+	}
+	r.Do(context.Background(), "DEL", redisIndexKey)
+}
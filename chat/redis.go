@@ -0,0 +1,202 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"stock-analysis-api/cache"
+	"stock-analysis-api/ids"
+	"stock-analysis-api/llm"
+)
+
+// RedisStore keeps sessions in Redis. Each session is a JSON value under
+// "chat:session:<id>" that expires ttl after its last update;
+// "chat:sessions" indexes the IDs by update time.
+type RedisStore struct {
+	redis *cache.Redis
+	ttl   time.Duration
+}
+
+func NewRedisStore(r *cache.Redis, ttl time.Duration) *RedisStore {
+	return &RedisStore{redis: r, ttl: ttl}
+}
+
+const redisIndexKey = "chat:sessions"
+
+func redisSessionKey(id string) string {
+	return "chat:session:" + id
+}
+
+func (s *RedisStore) Create(title string) (*Session, error) {
+	id, err := ids.New()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &Session{ID: id, Title: title, CreatedAt: now, UpdatedAt: now, Messages: []llm.Content{}}
+	if err := s.create(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *RedisStore) Get(id string) (*Session, error) {
+	value, ok, err := s.redis.Get(context.Background(), redisSessionKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %v", err)
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var session Session
+	if err := json.Unmarshal(value, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %v", err)
+	}
+	return &session, nil
+}
+
+// replaceSession sets KEYS[1] to ARGV[2] with a PX of ARGV[3] only if it
+// still holds ARGV[1]. It returns 1 when replaced and 0 when the session
+// changed or expired since it was read.
+const replaceSession = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`
+
+// updateAttempts bounds how often Update rereads a session that another
+// request changed in the meantime.
+const updateAttempts = 5
+
+// Update reads the session, applies fn and writes the result only if the
+// stored value is unchanged, retrying on a concurrent change.
+func (s *RedisStore) Update(id string, fn func(session *Session)) (*Session, error) {
+	ctx := context.Background()
+	key := redisSessionKey(id)
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		current, ok, err := s.redis.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read session: %v", err)
+		}
+		if !ok {
+			return nil, ErrNotFound
+		}
+		var session Session
+		if err := json.Unmarshal(current, &session); err != nil {
+			return nil, fmt.Errorf("failed to decode session: %v", err)
+		}
+		fn(&session)
+		session.ID = id
+		session.UpdatedAt = time.Now()
+		value, err := json.Marshal(&session)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode session: %v", err)
+		}
+
+		reply, err := s.redis.Do(ctx, "EVAL", replaceSession, "1", key,
+			string(current), string(value), strconv.FormatInt(s.ttl.Milliseconds(), 10))
+		if err != nil {
+			return nil, fmt.Errorf("failed to store session: %v", err)
+		}
+		if string(reply) != "1" {
+			continue
+		}
+		if err := s.index(&session); err != nil {
+			return nil, err
+		}
+		return &session, nil
+	}
+	return nil, fmt.Errorf("failed to store session: it kept changing during %d attempts", updateAttempts)
+}
+
+// create stores a new session unless its ID is taken, and indexes it.
+func (s *RedisStore) create(session *Session) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %v", err)
+	}
+	reply, err := s.redis.Do(context.Background(), "SET", redisSessionKey(session.ID), string(value),
+		"PX", strconv.FormatInt(s.ttl.Milliseconds(), 10), "NX")
+	if err != nil {
+		return fmt.Errorf("failed to store session: %v", err)
+	}
+	if reply == nil {
+		return fmt.Errorf("failed to store session: ID %s is taken", session.ID)
+	}
+	return s.index(session)
+}
+
+// index records the session's update time in the listing index.
+func (s *RedisStore) index(session *Session) error {
+	score := strconv.FormatInt(session.UpdatedAt.UnixMilli(), 10)
+	if _, err := s.redis.Do(context.Background(), "ZADD", redisIndexKey, score, session.ID); err != nil {
+		return fmt.Errorf("failed to index session: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) List() ([]Summary, error) {
+	ctx := context.Background()
+	// Drop index entries whose session has expired.
+	cutoff := strconv.FormatInt(time.Now().Add(-s.ttl).UnixMilli(), 10)
+	if _, err := s.redis.Do(ctx, "ZREMRANGEBYSCORE", redisIndexKey, "-inf", "("+cutoff); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+	ids, err := s.redis.Strings(ctx, "ZRANGE", redisIndexKey, "0", "-1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	out := make([]Summary, 0, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	args := []string{"MGET"}
+	for _, id := range ids {
+		args = append(args, redisSessionKey(id))
+	}
+	values, err := s.redis.Strings(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal([]byte(value), &session); err != nil {
+			continue
+		}
+		out = append(out, Summary{
+			ID:           session.ID,
+			Title:        session.Title,
+			CreatedAt:    session.CreatedAt,
+			UpdatedAt:    session.UpdatedAt,
+			MessageCount: len(session.Messages),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out, nil
+}
+
+func (s *RedisStore) Delete(id string) error {
+	ctx := context.Background()
+	reply, err := s.redis.Do(ctx, "DEL", redisSessionKey(id))
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %v", err)
+	}
+	if _, err := s.redis.Do(ctx, "ZREM", redisIndexKey, id); err != nil {
+		return fmt.Errorf("failed to delete session: %v", err)
+	}
+	if string(reply) == "0" {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Package ensemble runs the same analysis on several models or samples
+// concurrently and votes on the trade decision.
+package ensemble
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"stock-analysis-api/llm"
+	"stock-analysis-api/tradeplan"
+)
+
+// Params is the caller's ensemble request.
+type Params struct {
+	// Samples per member; 0 uses the configured default.
+	Samples int `json:"samples,omitempty"`
+}
+
+// Sample is the outcome of one ensemble member run.
+type Sample struct {
+	Member     string `json:"member"`
+	Model      string `json:"model,omitempty"`
+	Decision   string `json:"decision,omitempty"`
+	Confidence int    `json:"confidence,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	member llm.Member
+	err    error
+	resp   *llm.Response
+	plan   *tradeplan.TradePlan
+}
+
+// Spread is the range of one price level proposed by the samples that
+// voted for the winning decision.
+type Spread struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	// Pct is (Max-Min)/Min in percent.
+	Pct float64 `json:"pct"`
+}
+
+// Result is the aggregated vote.
+type Result struct {
+	Decision string         `json:"decision"`
+	Votes    map[string]int `json:"votes"`
+	// Agreement is the share of successful samples that voted for
+	// Decision. LowConsensus is set when it is below the configured
+	// minimum.
+	Agreement    float64           `json:"agreement"`
+	LowConsensus bool              `json:"low_consensus"`
+	Levels       map[string]Spread `json:"levels,omitempty"`
+	Samples      []Sample          `json:"samples"`
+
+	// Response and Plan come from the most confident sample of the
+	// majority; Usage covers every call made by the ensemble.
+	Response *llm.Response        `json:"-"`
+	Plan     *tradeplan.TradePlan `json:"-"`
+	Usage    llm.Usage            `json:"-"`
+}
+
+// conservative orders decisions for tie-breaking: the more cautious one
+// wins.
+var conservative = map[string]int{
+	tradeplan.DecisionAvoid: 0,
+	tradeplan.DecisionHold:  1,
+	tradeplan.DecisionBuy:   2,
+}
+
+// SampleCount resolves the number of samples per member and checks the
+// total against Config.Ensemble.MaxCalls.
+func SampleCount(client *llm.Client, params *Params) (int, error) {
+	cfg := client.Config().Ensemble
+	members, err := client.EnsembleMembers()
+	if err != nil {
+		return 0, err
+	}
+
+	samples := cfg.Samples
+	if params != nil && params.Samples != 0 {
+		samples = params.Samples
+	}
+	if samples < 1 {
+		return 0, fmt.Errorf("ensemble samples must be at least 1")
+	}
+	if total := samples * len(members); total > cfg.MaxCalls {
+		return 0, fmt.Errorf("ensemble of %d samples × %d members exceeds the limit of %d", samples, len(members), cfg.MaxCalls)
+	}
+	return samples, nil
+}
+
+// Run sends req to every member samples times in parallel, structures each
+// answer into a TradePlan and votes. It fails only when no sample
+// succeeded, returning the first error.
+func Run(ctx context.Context, client *llm.Client, req *llm.Request, tools *llm.ToolSet, samples int) (*Result, error) {
+	members, err := client.EnsembleMembers()
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Sample
+	for _, m := range members {
+		for n := 0; n < samples; n++ {
+			runs = append(runs, Sample{Member: m.Name, member: m})
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var usage llm.Usage
+	for i := range runs {
+		wg.Add(1)
+		go func(s *Sample) {
+			defer wg.Done()
+			u := runSample(ctx, req, tools, s)
+			mu.Lock()
+			usage.Add(u)
+			mu.Unlock()
+		}(&runs[i])
+	}
+	wg.Wait()
+
+	result := tally(runs, client.Config().Ensemble.MinAgreement)
+	if result == nil {
+		return nil, firstError(runs)
+	}
+	result.Usage = usage
+	return result, nil
+}
+
+// runSample fills s and returns the usage of its calls.
+func runSample(ctx context.Context, req *llm.Request, tools *llm.ToolSet, s *Sample) llm.Usage {
+	var usage llm.Usage
+
+	sampleReq := *req
+	sampleReq.Model = s.member.Model
+	// Cached answers would make every sample of a member identical.
+	sampleReq.NoCache = true
+	resp, err := s.member.Client.GenerateWithTools(ctx, &sampleReq, tools)
+	if err != nil {
+		s.err = err
+		s.Error = err.Error()
+		return usage
+	}
+	usage.Add(resp.Usage)
+	s.Model = resp.Model
+	s.resp = resp
+
+	plan, planResp, err := tradeplan.Structure(ctx, s.member.Client, resp.Text)
+	if planResp != nil {
+		usage.Add(planResp.Usage)
+	}
+	if err != nil {
+		s.err = err
+		s.Error = err.Error()
+		return usage
+	}
+	s.plan = plan
+	s.Decision = plan.Decision
+	s.Confidence = plan.Confidence
+	return usage
+}
+
+// tally counts the votes of the samples that produced a plan. It returns
+// nil when there are none.
+func tally(runs []Sample, minAgreement float64) *Result {
+	votes := map[string]int{}
+	valid := 0
+	for _, s := range runs {
+		if s.plan != nil {
+			votes[s.Decision]++
+			valid++
+		}
+	}
+	if valid == 0 {
+		return nil
+	}
+
+	decision := ""
+	for d, n := range votes {
+		if decision == "" || n > votes[decision] || n == votes[decision] && conservative[d] < conservative[decision] {
+			decision = d
+		}
+	}
+
+	result := &Result{
+		Decision:  decision,
+		Votes:     votes,
+		Agreement: float64(votes[decision]) / float64(valid),
+		Samples:   runs,
+	}
+	result.LowConsensus = result.Agreement < minAgreement
+
+	var majority []*tradeplan.TradePlan
+	for i := range runs {
+		s := &runs[i]
+		if s.plan == nil || s.Decision != decision {
+			continue
+		}
+		majority = append(majority, s.plan)
+		if result.Plan == nil || s.Confidence > result.Plan.Confidence {
+			result.Plan = s.plan
+			result.Response = s.resp
+		}
+	}
+	if decision == tradeplan.DecisionBuy {
+		result.Levels = levelSpreads(majority)
+	}
+	return result
+}
+
+func levelSpreads(plans []*tradeplan.TradePlan) map[string]Spread {
+	fields := map[string]func(p *tradeplan.TradePlan) float64{
+		"entry_low":  func(p *tradeplan.TradePlan) float64 { return p.Entry.Low },
+		"entry_high": func(p *tradeplan.TradePlan) float64 { return p.Entry.High },
+		"target_1":   func(p *tradeplan.TradePlan) float64 { return p.Target1 },
+		"target_2":   func(p *tradeplan.TradePlan) float64 { return p.Target2 },
+		"stop_loss":  func(p *tradeplan.TradePlan) float64 { return p.StopLoss },
+	}
+
+	spreads := map[string]Spread{}
+	for name, get := range fields {
+		var values []float64
+		for _, p := range plans {
+			if v := get(p); v > 0 {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		sort.Float64s(values)
+		lo, hi := values[0], values[len(values)-1]
+		spreads[name] = Spread{
+			Min: lo,
+			Max: hi,
+			Pct: math.Round((hi-lo)/lo*10000) / 100,
+		}
+	}
+	return spreads
+}
+
+// firstError prefers an upstream failure, whose type maps to a proper
+// HTTP status, over a plan that could not be parsed.
+func firstError(runs []Sample) error {
+	for _, s := range runs {
+		if s.err != nil && s.resp == nil {
+			return s.err
+		}
+	}
+	for _, s := range runs {
+		if s.err != nil {
+			return fmt.Errorf("no ensemble sample produced a trade plan: %v", s.err)
+		}
+	}
+	return fmt.Errorf("no ensemble sample produced a trade plan")
+}
@@ -0,0 +1,134 @@
+package idx
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// Boards with their own auto-rejection rules. The main, development and
+// new economy boards share the regular rules; the acceleration board and
+// the full call auction watchlist share the tighter ones.
+const (
+	BoardMain         = "main"
+	BoardDevelopment  = "development"
+	BoardNewEconomy   = "new-economy"
+	BoardAcceleration = "acceleration"
+	BoardWatchlist    = "watchlist"
+)
+
+// ARB modes on the regular boards: symmetric uses the ARA percentage of
+// the tier, asymmetric a flat asymmetricARBPct.
+const (
+	ARBSymmetric  = "symmetric"
+	ARBAsymmetric = "asymmetric"
+)
+
+const (
+	// asymmetricARBPct is the flat lower limit in asymmetric mode.
+	asymmetricARBPct = 15.0
+	// regularFloor and accelerationFloor are the lowest prices a stock
+	// can trade at on each kind of board.
+	regularFloor      = 50.0
+	accelerationFloor = 1.0
+)
+
+// Limits are the auto-rejection bounds (ARA/ARB) of one session.
+type Limits struct {
+	Board     string  `json:"board"`
+	ARBMode   string  `json:"arb_mode,omitempty"`
+	PrevClose float64 `json:"prev_close"`
+	// ARA and ARB are the highest and lowest valid prices of the session;
+	// the percentages are the rule they come from.
+	ARA    float64 `json:"ara"`
+	ARB    float64 `json:"arb"`
+	ARAPct float64 `json:"ara_pct"`
+	ARBPct float64 `json:"arb_pct"`
+	// BoardAssumed is set when no board was given and the main board
+	// rules were applied. A stock on the acceleration or watchlist board
+	// has tighter limits than reported.
+	BoardAssumed bool `json:"board_assumed,omitempty"`
+}
+
+// Within reports whether price can trade in the session.
+func (l Limits) Within(price float64) bool {
+	return price >= l.ARB && price <= l.ARA
+}
+
+// DefaultARBMode is the regular-board ARB mode from IDX_ARB_MODE. It
+// defaults to asymmetric, the rule in force since April 2025.
+func DefaultARBMode() string {
+	if mode := os.Getenv("IDX_ARB_MODE"); mode != "" {
+		return mode
+	}
+	return ARBAsymmetric
+}
+
+// AutoRejection returns the session limits for a stock that closed at
+// prevClose on board. An empty board is the main board and an empty mode
+// DefaultARBMode. Limit prices that fall between ticks are rounded
+// inward: ARA down, ARB up. Regular boards do not trade below Rp 50, so a
+// lower prevClose there is an error.
+func AutoRejection(prevClose float64, board, arbMode string) (Limits, error) {
+	if prevClose <= 0 {
+		return Limits{}, fmt.Errorf("previous close must be positive")
+	}
+	assumed := board == ""
+	if assumed {
+		board = BoardMain
+	}
+
+	l := Limits{Board: board, PrevClose: prevClose, BoardAssumed: assumed}
+	switch board {
+	case BoardMain, BoardDevelopment, BoardNewEconomy:
+		if prevClose < regularFloor {
+			return Limits{}, fmt.Errorf("previous close %g is below the Rp %g floor of the %s board", prevClose, regularFloor, board)
+		}
+		if arbMode == "" {
+			arbMode = DefaultARBMode()
+		}
+		l.ARBMode = arbMode
+		l.ARAPct = regularARAPct(prevClose)
+		switch arbMode {
+		case ARBSymmetric:
+			l.ARBPct = l.ARAPct
+		case ARBAsymmetric:
+			l.ARBPct = asymmetricARBPct
+		default:
+			return Limits{}, fmt.Errorf("unknown ARB mode %q", arbMode)
+		}
+		l.ARA = RoundDown(prevClose * (1 + l.ARAPct/100))
+		l.ARB = math.Max(RoundUp(prevClose*(1-l.ARBPct/100)), regularFloor)
+
+	case BoardAcceleration, BoardWatchlist:
+		// Stocks up to Rp 10 move at most one rupiah a session; above
+		// that the limit is 10% both ways.
+		if prevClose <= 10 {
+			l.ARA = prevClose + 1
+			l.ARB = math.Max(prevClose-1, accelerationFloor)
+			l.ARAPct = math.Round((l.ARA-prevClose)/prevClose*10000) / 100
+			l.ARBPct = math.Round((prevClose-l.ARB)/prevClose*10000) / 100
+		} else {
+			l.ARAPct, l.ARBPct = 10, 10
+			l.ARA = RoundDown(prevClose * 1.1)
+			l.ARB = math.Max(RoundUp(prevClose*0.9), accelerationFloor)
+		}
+
+	default:
+		return Limits{}, fmt.Errorf("unknown board %q", board)
+	}
+	return l, nil
+}
+
+// regularARAPct is the regular-board ARA percentage for the price tier of
+// prevClose.
+func regularARAPct(prevClose float64) float64 {
+	switch {
+	case prevClose <= 200:
+		return 35
+	case prevClose <= 5000:
+		return 25
+	default:
+		return 20
+	}
+}
@@ -0,0 +1,54 @@
+package idx
+
+import "testing"
+
+func TestAutoRejection(t *testing.T) {
+	tests := []struct {
+		name      string
+		prevClose float64
+		board     string
+		arbMode   string
+		ara, arb  float64
+		wantErr   bool
+	}{
+		{name: "top of the 35% tier", prevClose: 200, arbMode: ARBSymmetric, ara: 270, arb: 130},
+		{name: "bottom of the 25% tier", prevClose: 201, arbMode: ARBSymmetric, ara: 250, arb: 151},
+		{name: "top of the 25% tier", prevClose: 5000, arbMode: ARBSymmetric, ara: 6250, arb: 3750},
+		{name: "20% tier", prevClose: 5025, arbMode: ARBSymmetric, ara: 6025, arb: 4020},
+		{name: "asymmetric ARB", prevClose: 1450, arbMode: ARBAsymmetric, ara: 1810, arb: 1235},
+		{name: "ARB held at the floor", prevClose: 55, board: BoardDevelopment, arbMode: ARBSymmetric, ara: 74, arb: 50},
+		{name: "at the floor", prevClose: 50, arbMode: ARBAsymmetric, ara: 67, arb: 50},
+		{name: "below the floor", prevClose: 8, arbMode: ARBAsymmetric, wantErr: true},
+		{name: "below the floor on new economy", prevClose: 49, board: BoardNewEconomy, wantErr: true},
+		{name: "acceleration at Rp 10", prevClose: 10, board: BoardAcceleration, ara: 11, arb: 9},
+		{name: "acceleration at Rp 1", prevClose: 1, board: BoardAcceleration, ara: 2, arb: 1},
+		{name: "acceleration above Rp 10", prevClose: 11, board: BoardAcceleration, ara: 12, arb: 10},
+		{name: "watchlist 10%", prevClose: 150, board: BoardWatchlist, ara: 165, arb: 135},
+		{name: "zero close", prevClose: 0, wantErr: true},
+		{name: "unknown board", prevClose: 1000, board: "pink", wantErr: true},
+		{name: "unknown ARB mode", prevClose: 1000, arbMode: "wide", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AutoRejection(tt.prevClose, tt.board, tt.arbMode)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("AutoRejection(%v) = %+v, want an error", tt.prevClose, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AutoRejection(%v): %v", tt.prevClose, err)
+			}
+			if got.BoardAssumed != (tt.board == "") {
+				t.Errorf("AutoRejection(%v) on board %q: BoardAssumed = %v", tt.prevClose, tt.board, got.BoardAssumed)
+			}
+			if got.ARA != tt.ara || got.ARB != tt.arb {
+				t.Errorf("AutoRejection(%v) ARA/ARB = %v/%v, want %v/%v", tt.prevClose, got.ARA, got.ARB, tt.ara, tt.arb)
+			}
+			if !got.Within(got.ARA) || !got.Within(got.ARB) || got.Within(TickBelow(got.ARB)) {
+				t.Errorf("Within does not match the band %v-%v", got.ARB, got.ARA)
+			}
+		})
+	}
+}
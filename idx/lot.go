@@ -0,0 +1,43 @@
+package idx
+
+import (
+	"fmt"
+	"math"
+)
+
+// LotSize is the number of shares in one lot on the regular market.
+const LotSize = 100
+
+// Position is a budget converted into whole lots at one price.
+type Position struct {
+	Price  float64 `json:"price"`
+	Budget float64 `json:"budget"`
+	Lots   int     `json:"lots"`
+	Shares int     `json:"shares"`
+	// Cost is Shares × Price and Leftover what remains of Budget. Broker
+	// fees are not included.
+	Cost     float64 `json:"cost"`
+	Leftover float64 `json:"leftover"`
+}
+
+// Size buys as many whole lots as budget allows at price. A budget below
+// one lot gives zero lots with the whole budget left over.
+func Size(budget, price float64) (Position, error) {
+	if price <= 0 {
+		return Position{}, fmt.Errorf("price must be positive")
+	}
+	if budget < 0 {
+		return Position{}, fmt.Errorf("budget must not be negative")
+	}
+	lots := int(math.Floor(budget / (price * LotSize)))
+	shares := lots * LotSize
+	cost := float64(shares) * price
+	return Position{
+		Price:    price,
+		Budget:   budget,
+		Lots:     lots,
+		Shares:   shares,
+		Cost:     cost,
+		Leftover: budget - cost,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package idx
+
+import "testing"
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		budget, price float64
+		want          Position
+		wantErr       bool
+	}{
+		{
+			name:   "exactly one lot",
+			budget: 145_000, price: 1450,
+			want: Position{Price: 1450, Budget: 145_000, Lots: 1, Shares: 100, Cost: 145_000},
+		},
+		{
+			name:   "just below one lot",
+			budget: 144_999, price: 1450,
+			want: Position{Price: 1450, Budget: 144_999, Leftover: 144_999},
+		},
+		{
+			name:   "leftover below a lot",
+			budget: 7_500_000, price: 1450,
+			want: Position{Price: 1450, Budget: 7_500_000, Lots: 51, Shares: 5100, Cost: 7_395_000, Leftover: 105_000},
+		},
+		{
+			name:   "zero budget",
+			budget: 0, price: 1450,
+			want: Position{Price: 1450},
+		},
+		{name: "zero price", budget: 1_000_000, price: 0, wantErr: true},
+		{name: "negative budget", budget: -1, price: 1450, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Size(tt.budget, tt.price)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Size(%v, %v) error = %v, want error %v", tt.budget, tt.price, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Size(%v, %v) = %+v, want %+v", tt.budget, tt.price, got, tt.want)
+			}
+		})
+	}
+}
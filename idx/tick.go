@@ -0,0 +1,66 @@
+// Package idx implements the trading rules of the Indonesia Stock Exchange
+// that constrain the price levels and sizes of a trade plan.
+package idx
+
+import "math"
+
+// tickBands is the IDX price fraction (fraksi harga) table: a price at or
+// above From moves in steps of Tick.
+var tickBands = []struct {
+	From float64
+	Tick float64
+}{
+	{5000, 25},
+	{2000, 10},
+	{500, 5},
+	{200, 2},
+	{0, 1},
+}
+
+// TickSize returns the price fraction that applies at price.
+func TickSize(price float64) float64 {
+	for _, b := range tickBands {
+		if price >= b.From {
+			return b.Tick
+		}
+	}
+	return 1
+}
+
+// ValidTick reports whether price is a valid bid or offer.
+func ValidTick(price float64) bool {
+	if price <= 0 {
+		return false
+	}
+	tick := TickSize(price)
+	return math.Mod(price, tick) == 0
+}
+
+// RoundDown returns the highest valid price at or below price.
+func RoundDown(price float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	tick := TickSize(price)
+	return math.Floor(price/tick) * tick
+}
+
+// RoundUp returns the lowest valid price at or above price. Band
+// boundaries are multiples of the larger fraction above them, so rounding
+// up with the fraction of price always lands on a valid tick.
+func RoundUp(price float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	tick := TickSize(price)
+	return math.Ceil(price/tick) * tick
+}
+
+// TickBelow returns the valid price one step below price.
+func TickBelow(price float64) float64 {
+	down := RoundDown(price)
+	if down < price {
+		return down
+	}
+	return RoundDown(price - TickSize(math.Nextafter(price, 0)))
+}
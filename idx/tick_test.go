@@ -0,0 +1,82 @@
+package idx
+
+import "testing"
+
+func TestTickSize(t *testing.T) {
+	tests := []struct {
+		price, want float64
+	}{
+		{50, 1},
+		{199, 1},
+		{199.5, 1},
+		{200, 2},
+		{498, 2},
+		{500, 5},
+		{1995, 5},
+		{2000, 10},
+		{4990, 10},
+		{5000, 25},
+		{10000, 25},
+	}
+	for _, tt := range tests {
+		if got := TickSize(tt.price); got != tt.want {
+			t.Errorf("TickSize(%v) = %v, want %v", tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestValidTick(t *testing.T) {
+	tests := []struct {
+		price float64
+		want  bool
+	}{
+		{199, true},
+		{200, true},
+		{201, false},
+		{202, true},
+		{505, true},
+		{507, false},
+		{2010, true},
+		{2015, false},
+		{5025, true},
+		{5010, false},
+		{0, false},
+		{199.5, false},
+	}
+	for _, tt := range tests {
+		if got := ValidTick(tt.price); got != tt.want {
+			t.Errorf("ValidTick(%v) = %v, want %v", tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		price           float64
+		down, up, below float64
+	}{
+		{199, 199, 199, 198},
+		{199.5, 199, 200, 199},
+		{200, 200, 200, 199},
+		{201, 200, 202, 200},
+		{202, 202, 202, 200},
+		{500, 500, 500, 498},
+		{503, 500, 505, 500},
+		{2000, 2000, 2000, 1995},
+		{2004, 2000, 2010, 2000},
+		{5000, 5000, 5000, 4990},
+		{5010, 5000, 5025, 5000},
+		{5025, 5025, 5025, 5000},
+	}
+	for _, tt := range tests {
+		if got := RoundDown(tt.price); got != tt.down {
+			t.Errorf("RoundDown(%v) = %v, want %v", tt.price, got, tt.down)
+		}
+		if got := RoundUp(tt.price); got != tt.up {
+			t.Errorf("RoundUp(%v) = %v, want %v", tt.price, got, tt.up)
+		}
+		if got := TickBelow(tt.price); got != tt.below {
+			t.Errorf("TickBelow(%v) = %v, want %v", tt.price, got, tt.below)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package idx
+
+import "time"
+
+// WIB is Jakarta time, in which IDX sessions are scheduled and trading days
+// are counted.
+var WIB = time.FixedZone("WIB", 7*60*60)
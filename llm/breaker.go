@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrAllModelsUnavailable is wrapped in an ExhaustedError when every model
+// in the chain is skipped because its circuit breaker is open.
+var ErrAllModelsUnavailable = errors.New("all configured models are temporarily unavailable")
+
+// BreakerConfig controls the per-model circuit breaker.
+type BreakerConfig struct {
+	// FailureThreshold consecutive failures open the breaker; 0 disables it.
+	FailureThreshold int      `json:"failure_threshold"`
+	Cooldown         Duration `json:"cooldown"`
+}
+
+type modelState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// breaker tracks model health across requests. After Cooldown an open
+// breaker lets the next request through; a success closes it again and a
+// failure reopens it immediately.
+type breaker struct {
+	cfg BreakerConfig
+
+	mu     sync.Mutex
+	models map[string]*modelState
+}
+
+func newBreaker(cfg BreakerConfig) *breaker {
+	return &breaker{cfg: cfg, models: make(map[string]*modelState)}
+}
+
+// allow reports whether model may be called now and, if not, how long
+// until it may.
+func (b *breaker) allow(model string) (bool, time.Duration) {
+	if b.cfg.FailureThreshold <= 0 {
+		return true, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, ok := b.models[model]
+	if !ok {
+		return true, 0
+	}
+	if wait := time.Until(st.openUntil); wait > 0 {
+		return false, wait
+	}
+	return true, 0
+}
+
+func (b *breaker) success(model string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.models, model)
+}
+
+func (b *breaker) failure(model string) {
+	if b.cfg.FailureThreshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, ok := b.models[model]
+	if !ok {
+		st = &modelState{}
+		b.models[model] = st
+	}
+	st.failures++
+	if st.failures >= b.cfg.FailureThreshold {
+		st.openUntil = time.Now().Add(time.Duration(b.cfg.Cooldown))
+	}
+}
+
+// shouldFallback reports whether err is specific to the model that was
+// tried, so the next model in the chain may still succeed.
+func shouldFallback(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return true
+	}
+	var exhausted *ExhaustedError
+	return errors.As(err, &exhausted) || IsRetryable(err)
+}
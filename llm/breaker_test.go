@@ -0,0 +1,86 @@
+package llm_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"stock-analysis-api/llm"
+	"stock-analysis-api/llm/llmtest"
+)
+
+// models returns the model named in the path of each call.
+func models(srv *llmtest.Server) []string {
+	var out []string
+	for _, c := range srv.Calls() {
+		name := strings.TrimPrefix(c.Path[strings.LastIndex(c.Path, "/")+1:], "models/")
+		out = append(out, strings.SplitN(name, ":", 2)[0])
+	}
+	return out
+}
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 100 * time.Millisecond
+	breaker := func(cfg *llm.Config) {
+		cfg.Retry.MaxAttempts = 1
+		cfg.Breaker = llm.BreakerConfig{FailureThreshold: 1, Cooldown: llm.Duration(cooldown)}
+	}
+
+	t.Run("open breaker skips to the fallback until the cooldown ends", func(t *testing.T) {
+		srv := llmtest.NewServer(
+			llmtest.Reply{Status: 503, ErrorMessage: "overloaded"},
+			llmtest.Reply{Text: "from fallback"},
+		)
+		defer srv.Close()
+		client := newClient(t, srv, func(cfg *llm.Config) {
+			breaker(cfg)
+			cfg.FallbackModels = []string{"fallback"}
+		})
+
+		resp, err := generate(client)
+		if err != nil {
+			t.Fatalf("first Generate: %v", err)
+		}
+		if resp.Model != "fallback" {
+			t.Errorf("model = %q, want fallback", resp.Model)
+		}
+
+		if _, err := generate(client); err != nil {
+			t.Fatalf("second Generate: %v", err)
+		}
+		time.Sleep(cooldown + 20*time.Millisecond)
+		if _, err := generate(client); err != nil {
+			t.Fatalf("third Generate: %v", err)
+		}
+
+		want := []string{"primary", "fallback", "fallback", "primary"}
+		if got := models(srv); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("models called = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("every breaker open", func(t *testing.T) {
+		srv := llmtest.NewServer(llmtest.Reply{Status: 503, ErrorMessage: "overloaded"})
+		defer srv.Close()
+		client := newClient(t, srv, breaker)
+
+		if _, err := generate(client); err == nil {
+			t.Fatal("first Generate succeeded, want an error")
+		}
+		_, err := generate(client)
+		if !errors.Is(err, llm.ErrAllModelsUnavailable) {
+			t.Fatalf("second Generate error = %v, want ErrAllModelsUnavailable", err)
+		}
+		if got := llm.HTTPStatus(err); got != http.StatusServiceUnavailable {
+			t.Errorf("HTTPStatus = %d, want 503", got)
+		}
+		if got := llm.RetryAfterSeconds(err); got != 1 {
+			t.Errorf("RetryAfterSeconds = %d, want 1", got)
+		}
+		if got := len(srv.Calls()); got != 1 {
+			t.Errorf("calls = %d, want 1", got)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"stock-analysis-api/cache"
+)
+
+// cacheKeyVersion is bumped when the key layout or the stored Response
+// changes, so old entries are ignored.
+const cacheKeyVersion = "llm-v1"
+
+type cacheEntry struct {
+	Response  *Response `json:"response"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// cacheEnabled reports whether req's endpoint is configured for caching.
+func (c *Client) cacheEnabled(req *Request) bool {
+	if c.cache == nil || req.NoCache {
+		return false
+	}
+	ep := c.cfg.Endpoints[req.Endpoint]
+	return ep.Cache != nil && *ep.Cache
+}
+
+// cacheKey hashes everything that shapes the answer: the model chain, the
+// resolved system instruction and safety settings, the generation
+// parameters, the offered tools and the prompt with whitespace normalized.
+func (c *Client) cacheKey(req *Request, tools *ToolSet) (string, error) {
+	prepared, err := c.prepare(req)
+	if err != nil {
+		return "", err
+	}
+
+	keyed := struct {
+		Endpoint   string                `json:"endpoint"`
+		Models     []string              `json:"models"`
+		System     *Content              `json:"system,omitempty"`
+		Contents   []Content             `json:"contents"`
+		Generation *GenerationConfig     `json:"generation,omitempty"`
+		Safety     []SafetySetting       `json:"safety,omitempty"`
+		Tools      []Tool                `json:"tools,omitempty"`
+		ToolSet    []FunctionDeclaration `json:"tool_set,omitempty"`
+	}{
+		Endpoint:   req.Endpoint,
+		Models:     c.cfg.modelsFor(req),
+		System:     prepared.SystemInstruction,
+		Contents:   normalizeContents(prepared.Contents),
+		Generation: prepared.GenerationConfig,
+		Safety:     prepared.SafetySettings,
+		Tools:      prepared.Tools,
+	}
+	if tools != nil {
+		keyed.ToolSet = tools.decls
+	}
+
+	data, err := json.Marshal(keyed)
+	if err != nil {
+		return "", err
+	}
+	return cache.Key([]byte(cacheKeyVersion), data), nil
+}
+
+// normalizeContents collapses whitespace in text parts, so prompts that
+// differ only in indentation or trailing spaces share an entry.
+func normalizeContents(contents []Content) []Content {
+	out := make([]Content, len(contents))
+	for i, content := range contents {
+		out[i] = Content{Role: content.Role, Parts: make([]Part, len(content.Parts))}
+		for j, p := range content.Parts {
+			p.Text = strings.Join(strings.Fields(p.Text), " ")
+			out[i].Parts[j] = p
+		}
+	}
+	return out
+}
+
+// cached serves req from the cache when possible and otherwise calls
+// generate, storing a complete answer until the end of the trading day.
+// Cache failures are logged and treated as misses.
+func (c *Client) cached(ctx context.Context, req *Request, tools *ToolSet, generate func() (*Response, error)) (*Response, error) {
+	if !c.cacheEnabled(req) {
+		return generate()
+	}
+	key, err := c.cacheKey(req, tools)
+	if err != nil {
+		// generate reports the same configuration error.
+		return generate()
+	}
+
+	if !req.Refresh {
+		data, ok, err := c.cache.Get(ctx, key)
+		if err != nil {
+			log.Printf("llm: response cache lookup failed: %v", err)
+		}
+		var entry cacheEntry
+		if ok && json.Unmarshal(data, &entry) == nil && entry.Response != nil {
+			resp := entry.Response
+			resp.Cached = true
+			resp.CacheExpires = entry.ExpiresAt
+			resp.Usage = Usage{}
+			return resp, nil
+		}
+	}
+
+	resp, err := generate()
+	if err != nil || len(resp.FunctionCalls) > 0 || resp.Truncated {
+		return resp, err
+	}
+
+	ttl := cache.UntilTradingDayEnd(time.Now())
+	entry := cacheEntry{Response: resp, ExpiresAt: time.Now().Add(ttl)}
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = c.cache.Set(ctx, key, data, ttl)
+	}
+	if err != nil {
+		log.Printf("llm: response cache store failed: %v", err)
+		return resp, nil
+	}
+	resp.CacheExpires = entry.ExpiresAt
+	return resp, nil
+}
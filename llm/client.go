@@ -0,0 +1,308 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"stock-analysis-api/cache"
+)
+
+// Client applies Config to requests and forwards them to a Provider.
+type Client struct {
+	cfg      Config
+	provider Provider
+	breaker  *breaker
+	usage    UsageStore
+	cache    cache.Cache
+	limiter  *limiter
+
+	membersOnce sync.Once
+	members     []Member
+	membersErr  error
+}
+
+// NewClient builds a Client for the provider named in cfg.
+func NewClient(cfg Config) (*Client, error) {
+	cfg.applyProviderDefaults()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	httpClient := &http.Client{Timeout: time.Duration(cfg.Timeout)}
+
+	var p Provider
+	switch cfg.Provider {
+	case "gemini", "":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("GEMINI_API_KEY is not set")
+		}
+		p = NewGemini(cfg.BaseURL, cfg.APIKey, httpClient)
+	case "openai":
+		// The key is optional: local servers such as vLLM or LM Studio
+		// usually run without authentication.
+		p = NewOpenAI(cfg.BaseURL, cfg.APIKey, httpClient)
+	case "ollama":
+		p = NewOllama(cfg.BaseURL, httpClient)
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+
+	store, err := cache.New(cfg.Cache.URL, cfg.Cache.Size)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := cache.Shared()
+	if err != nil {
+		return nil, err
+	}
+	client := NewClientWithProvider(cfg, p)
+	client.cache = store
+	if shared != nil {
+		client.usage = NewRedisUsage(shared)
+		client.limiter = newSharedLimiter(cfg.Limiter, shared, limiterLease(cfg))
+	}
+	return client, nil
+}
+
+// NewClientWithProvider wraps an already constructed Provider. It always
+// caches and counts usage in memory; Config.Cache.URL and STORE_URL are
+// only honored by NewClient.
+func NewClientWithProvider(cfg Config, p Provider) *Client {
+	cfg.applyProviderDefaults()
+	return &Client{
+		cfg:      cfg,
+		provider: p,
+		breaker:  newBreaker(cfg.Breaker),
+		usage:    NewUsageTracker(),
+		cache:    cache.NewLRU(cfg.Cache.Size),
+		limiter:  newLimiter(cfg.Limiter),
+	}
+}
+
+// limiterLease is how long a shared limiter slot survives an instance that
+// died while holding it: one upstream call plus some slack.
+func limiterLease(cfg Config) time.Duration {
+	if cfg.Timeout <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(cfg.Timeout) + 30*time.Second
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultClient *Client
+	defaultErr    error
+)
+
+// Default returns the process-wide Client built from LoadConfig. The result
+// is cached, so configuration is read once per process.
+func Default() (*Client, error) {
+	defaultOnce.Do(func() {
+		cfg, err := LoadConfig()
+		if err != nil {
+			defaultErr = err
+			return
+		}
+		defaultClient, defaultErr = NewClient(cfg)
+	})
+	return defaultClient, defaultErr
+}
+
+// Usage returns the store that aggregates token usage of this client.
+func (c *Client) Usage() UsageStore {
+	return c.usage
+}
+
+// Config returns the configuration the client was built with.
+func (c *Client) Config() Config {
+	return c.cfg
+}
+
+// Generate runs req against the model chain for its endpoint. Each model is
+// retried according to Config.Retry; a model that keeps failing, or whose
+// circuit breaker is open, is skipped in favour of the next one. The model
+// that produced the answer is reported in Response.Model.
+func (c *Client) Generate(ctx context.Context, req *Request) (*Response, error) {
+	return c.cached(ctx, req, nil, func() (*Response, error) {
+		return c.generate(ctx, req)
+	})
+}
+
+// generate is Generate without the response cache.
+func (c *Client) generate(ctx context.Context, req *Request) (*Response, error) {
+	return c.withFallback(ctx, req, func(r *Request) (*Response, error) {
+		call := func(r *Request) (*Response, error) {
+			return withRetry(ctx, c.cfg.Retry, func() (*Response, error) {
+				return c.limited(ctx, r, func() (*Response, error) {
+					return c.provider.Generate(ctx, r)
+				})
+			})
+		}
+		resp, err := call(r)
+		if err != nil {
+			return nil, err
+		}
+		return c.continueTruncated(r, resp, call), nil
+	})
+}
+
+// Stream is like Generate but delivers text incrementally through onChunk.
+// Providers that cannot stream deliver the full text as one chunk. Retries
+// and fallbacks only happen while nothing has been forwarded yet.
+func (c *Client) Stream(ctx context.Context, req *Request, onChunk ChunkFunc) (*Response, error) {
+	sp, canStream := c.provider.(StreamProvider)
+	if !canStream {
+		resp, err := c.Generate(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if err := onChunk(resp.Text); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	started := false
+	return c.withFallback(ctx, req, func(r *Request) (*Response, error) {
+		call := func(r *Request) (*Response, error) {
+			return withRetry(ctx, c.cfg.Retry, func() (*Response, error) {
+				resp, err := c.limited(ctx, r, func() (*Response, error) {
+					return sp.Stream(ctx, r, func(text string) error {
+						started = true
+						return onChunk(text)
+					})
+				})
+				if err != nil && started {
+					return nil, &streamBrokenError{err}
+				}
+				return resp, err
+			})
+		}
+		resp, err := call(r)
+		if err != nil {
+			return nil, err
+		}
+		return c.continueTruncated(r, resp, call), nil
+	})
+}
+
+// continuePrompt asks the model to resume a reply cut off at MAX_TOKENS.
+const continuePrompt = "Your previous reply was cut off. Continue exactly where it stopped, without repeating anything and without any preamble."
+
+// continueTruncated sends follow-up requests while resp stopped at
+// MAX_TOKENS, appending each part to resp. The conversation so far is
+// replayed with the partial answer as a model turn. A failed follow-up
+// keeps what was already produced and marks it Truncated.
+func (c *Client) continueTruncated(req *Request, resp *Response, call func(*Request) (*Response, error)) *Response {
+	for resp.FinishReason == FinishMaxTokens {
+		if resp.Continuations >= c.cfg.MaxContinuations {
+			resp.Truncated = true
+			return resp
+		}
+
+		next := *req
+		next.Contents = append(append([]Content(nil), req.Contents...),
+			Content{Role: RoleModel, Parts: []Part{{Text: resp.Text}}},
+			Content{Role: RoleUser, Parts: []Part{{Text: continuePrompt}}},
+		)
+
+		more, err := call(&next)
+		if err != nil {
+			resp.Truncated = true
+			return resp
+		}
+		resp.Text += more.Text
+		resp.FinishReason = more.FinishReason
+		resp.Usage.Add(more.Usage)
+		resp.Continuations++
+	}
+	return resp
+}
+
+// withFallback walks the model chain for req, calling attempt with a copy
+// of req bound to each model in turn.
+func (c *Client) withFallback(ctx context.Context, req *Request, attempt func(*Request) (*Response, error)) (*Response, error) {
+	prepared, err := c.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	var minWait time.Duration
+
+	for _, model := range c.cfg.modelsFor(req) {
+		if ok, wait := c.breaker.allow(model); !ok {
+			if minWait == 0 || wait < minWait {
+				minWait = wait
+			}
+			continue
+		}
+
+		resolved := *prepared
+		resolved.Model = model
+		resp, err := attempt(&resolved)
+		if err == nil {
+			c.breaker.success(model)
+			resp.SafetyProfile = prepared.SafetyProfile
+			resp.Persona = prepared.Persona
+			resp.Usage.CostUSD = estimateCost(c.cfg.Pricing, model, resp.Usage)
+			if err := c.usage.Record(ctx, time.Now(), req.Endpoint, model, resp.Usage); err != nil {
+				log.Printf("llm: %v", err)
+			}
+			return resp, nil
+		}
+
+		lastErr = err
+		if !shouldFallback(err) {
+			return nil, err
+		}
+		c.breaker.failure(model)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, &ExhaustedError{Err: ErrAllModelsUnavailable, RetryAfter: minWait}
+}
+
+// prepare copies req and applies endpoint configuration that does not
+// depend on the model.
+func (c *Client) prepare(req *Request) (*Request, error) {
+	prepared := *req
+	if prepared.SafetySettings == nil {
+		name, settings, err := c.cfg.safetyProfileFor(req)
+		if err != nil {
+			return nil, err
+		}
+		prepared.SafetyProfile = name
+		prepared.SafetySettings = settings
+	} else {
+		prepared.SafetyProfile = "custom"
+	}
+	if prepared.SystemInstruction == nil {
+		name, text, err := c.cfg.personaFor(req)
+		if err != nil {
+			return nil, err
+		}
+		prepared.Persona = name
+		if text != "" {
+			prepared.SystemInstruction = &Content{Parts: []Part{{Text: text}}}
+		}
+	} else {
+		prepared.Persona = "custom"
+	}
+	return &prepared, nil
+}
+
+// streamBrokenError marks a failure after output was already forwarded,
+// which must not be retried. It has no Unwrap on purpose, so IsRetryable
+// does not see the transient cause.
+type streamBrokenError struct{ err error }
+
+func (e *streamBrokenError) Error() string { return e.err.Error() }
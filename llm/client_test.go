@@ -0,0 +1,39 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"stock-analysis-api/llm"
+	"stock-analysis-api/llm/llmtest"
+)
+
+// newClient builds a client for srv with fast retries and the breaker
+// disabled unless a test turns it on.
+func newClient(t *testing.T, srv *llmtest.Server, tweak func(*llm.Config)) *llm.Client {
+	t.Helper()
+	cfg := srv.GeminiConfig()
+	cfg.Model = "primary"
+	cfg.Retry = llm.RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   llm.Duration(time.Millisecond),
+		MaxDelay:    llm.Duration(50 * time.Millisecond),
+	}
+	cfg.Breaker = llm.BreakerConfig{}
+	if tweak != nil {
+		tweak(&cfg)
+	}
+	client, err := llm.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func generate(client *llm.Client) (*llm.Response, error) {
+	return client.Generate(context.Background(), &llm.Request{
+		Endpoint: "prompt",
+		Contents: llm.UserText("hello"),
+	})
+}
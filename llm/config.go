@@ -0,0 +1,398 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the single configuration point for all LLM calls. It is built
+// from defaults, then an optional JSON file named by LLM_CONFIG_FILE, then
+// environment variables, in that order of precedence.
+type Config struct {
+	Provider string   `json:"provider"`
+	APIKey   string   `json:"-"`
+	BaseURL  string   `json:"base_url"`
+	Model    string   `json:"model"`
+	Timeout  Duration `json:"timeout"`
+
+	// FallbackModels are tried in order after Model fails or while its
+	// circuit breaker is open.
+	FallbackModels []string `json:"fallback_models"`
+
+	Retry   RetryConfig   `json:"retry"`
+	Breaker BreakerConfig `json:"breaker"`
+
+	// MaxContinuations caps the follow-up requests sent when a model stops
+	// at MAX_TOKENS; 0 returns truncated output as is.
+	MaxContinuations int `json:"max_continuations"`
+
+	// GenerationLimits bound caller-supplied generation settings.
+	GenerationLimits GenerationLimits `json:"generation_limits"`
+
+	// SafetyProfiles are named sets of safety settings that endpoints
+	// select by name.
+	SafetyProfiles map[string][]SafetySetting `json:"safety_profiles"`
+
+	// Personas are named system instructions that endpoints select by
+	// name.
+	Personas map[string]string `json:"personas"`
+
+	// Pricing maps model names to token prices for cost estimates.
+	Pricing map[string]ModelPrice `json:"pricing"`
+
+	// ContextWindows maps model names to their input token limits.
+	ContextWindows map[string]int `json:"context_windows"`
+
+	// Endpoints holds per-endpoint overrides keyed by Request.Endpoint.
+	Endpoints map[string]EndpointConfig `json:"endpoints"`
+
+	// Ensemble configures analyses sampled from several models at once.
+	Ensemble EnsembleConfig `json:"ensemble"`
+
+	// Cache selects the response cache backend.
+	Cache CacheConfig `json:"cache"`
+
+	// Limiter bounds concurrent upstream calls; waiting calls are served
+	// by EndpointConfig.Priority.
+	Limiter LimiterConfig `json:"limiter"`
+}
+
+// CacheConfig selects the response cache. URL is "memory" (default),
+// "none" or "redis://[:password@]host:port[/db]"; Size bounds the
+// in-memory LRU.
+type CacheConfig struct {
+	URL  string `json:"url"`
+	Size int    `json:"size"`
+}
+
+type EndpointConfig struct {
+	Model string `json:"model,omitempty"`
+	// FallbackModels replaces Config.FallbackModels for this endpoint.
+	FallbackModels []string `json:"fallback_models,omitempty"`
+	// Generation holds the defaults used when the caller sets nothing.
+	Generation GenerationConfig `json:"generation"`
+	// GenerationLimits replaces Config.GenerationLimits for this endpoint.
+	GenerationLimits *GenerationLimits `json:"generation_limits,omitempty"`
+	// SafetyProfile names an entry in Config.SafetyProfiles.
+	SafetyProfile string `json:"safety_profile,omitempty"`
+	// Persona names an entry in Config.Personas sent as the system
+	// instruction.
+	Persona string `json:"persona,omitempty"`
+	// Cache enables the response cache. Entries live until the end of the
+	// IDX trading day.
+	Cache *bool `json:"cache,omitempty"`
+	// Priority orders calls waiting for a limiter slot; higher goes first.
+	Priority int `json:"priority,omitempty"`
+}
+
+// withDefaults fills the fields of ep that were left unset from def.
+func (ep EndpointConfig) withDefaults(def EndpointConfig) EndpointConfig {
+	if ep.Generation == (GenerationConfig{}) {
+		ep.Generation = def.Generation
+	}
+	if ep.GenerationLimits == nil {
+		ep.GenerationLimits = def.GenerationLimits
+	}
+	if ep.SafetyProfile == "" {
+		ep.SafetyProfile = def.SafetyProfile
+	}
+	if ep.Persona == "" {
+		ep.Persona = def.Persona
+	}
+	if ep.Cache == nil {
+		ep.Cache = def.Cache
+	}
+	if ep.Priority == 0 {
+		ep.Priority = def.Priority
+	}
+	return ep
+}
+
+// Duration is a time.Duration that reads "60s"-style strings from JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"60s\": %v", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// DefaultConfig returns the provider-independent defaults. BaseURL and Model
+// are left empty and filled per provider by NewClient.
+func DefaultConfig() Config {
+	return Config{
+		Provider: "gemini",
+		Timeout:  Duration(60 * time.Second),
+		Retry: RetryConfig{
+			MaxAttempts: 3,
+			BaseDelay:   Duration(time.Second),
+			MaxDelay:    Duration(20 * time.Second),
+		},
+		Breaker: BreakerConfig{
+			FailureThreshold: 3,
+			Cooldown:         Duration(2 * time.Minute),
+		},
+		MaxContinuations: 2,
+		Ensemble: EnsembleConfig{
+			Samples:      3,
+			MaxCalls:     6,
+			MinAgreement: 0.6,
+		},
+		Cache: CacheConfig{URL: "memory", Size: 256},
+		Limiter: LimiterConfig{
+			MaxConcurrent: 4,
+			MaxQueue:      32,
+			MaxWait:       Duration(30 * time.Second),
+		},
+		GenerationLimits: GenerationLimits{
+			Temperature:     FloatRange{Min: 0, Max: 1.5},
+			TopK:            IntRange{Min: 1, Max: 100},
+			TopP:            FloatRange{Min: 0, Max: 1},
+			MaxOutputTokens: IntRange{Min: 256, Max: 8192},
+		},
+		SafetyProfiles: defaultSafetyProfiles(),
+		Personas:       defaultPersonas(),
+		Pricing:        defaultPricing(),
+		ContextWindows: defaultContextWindows(),
+		Endpoints: map[string]EndpointConfig{
+			// Price levels need consistency more than creativity.
+			"analyze": {
+				Generation: GenerationConfig{
+					Temperature:     Float(0.2),
+					TopK:            Int(40),
+					TopP:            Float(0.9),
+					MaxOutputTokens: Int(8192),
+				},
+				SafetyProfile: "market-analysis",
+				Persona:       "portfolio-manager",
+				Priority:      30,
+			},
+			"daily": {
+				Generation: GenerationConfig{
+					Temperature:     Float(0.4),
+					TopK:            Int(40),
+					TopP:            Float(0.95),
+					MaxOutputTokens: Int(8192),
+				},
+				SafetyProfile: "market-analysis",
+				Persona:       "head-trader",
+				// The date is the only input, so one answer per trading
+				// day is enough.
+				Cache:    Bool(true),
+				Priority: 20,
+			},
+			// trade-plan turns a finished analysis into JSON, which is
+			// transcription rather than judgement.
+			"trade-plan": {
+				Generation: GenerationConfig{
+					Temperature:     Float(0),
+					MaxOutputTokens: Int(1024),
+				},
+				SafetyProfile: "market-analysis",
+				Cache:         Bool(true),
+				// Structuring finishes a request that already holds
+				// output, so it goes ahead of new work.
+				Priority: 30,
+			},
+			"prompt": {
+				Generation: GenerationConfig{
+					Temperature: Float(0.7),
+				},
+				SafetyProfile: "strict",
+				Priority:      10,
+			},
+		},
+	}
+}
+
+// applyProviderDefaults fills BaseURL and Model when they were not set. For
+// Gemini this matches what the handlers used before they shared a client.
+func (c *Config) applyProviderDefaults() {
+	if c.Endpoints == nil {
+		c.Endpoints = map[string]EndpointConfig{}
+	}
+	modelSet := c.Model != ""
+
+	switch c.Provider {
+	case "gemini":
+		if c.BaseURL == "" {
+			c.BaseURL = "https://generativelanguage.googleapis.com"
+		}
+		if !modelSet {
+			c.Model = "gemini-2.0-flash"
+			if c.FallbackModels == nil {
+				c.FallbackModels = []string{"gemini-1.5-flash"}
+			}
+			if ep := c.Endpoints["prompt"]; ep.Model == "" {
+				ep.Model = "gemini-1.5-flash"
+				ep.FallbackModels = []string{"gemini-2.0-flash"}
+				c.Endpoints["prompt"] = ep
+			}
+		}
+	case "openai":
+		if c.BaseURL == "" {
+			c.BaseURL = "https://api.openai.com/v1"
+		}
+		if !modelSet {
+			c.Model = "gpt-4o-mini"
+		}
+	case "ollama":
+		if c.BaseURL == "" {
+			c.BaseURL = "http://localhost:11434"
+		}
+		if !modelSet {
+			c.Model = "llama3.1"
+		}
+	}
+}
+
+// LoadConfig builds the Config from LLM_CONFIG_FILE and the environment.
+func LoadConfig() (Config, error) {
+	cfg := DefaultConfig()
+
+	if path := os.Getenv("LLM_CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read LLM config: %v", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("failed to parse LLM config %s: %v", path, err)
+		}
+		// An endpoint listed in the file replaces the built-in entry as a
+		// whole, so carry over the defaults it did not mention.
+		for name, def := range DefaultConfig().Endpoints {
+			if ep, ok := cfg.Endpoints[name]; ok {
+				cfg.Endpoints[name] = ep.withDefaults(def)
+			}
+		}
+	}
+
+	if v := os.Getenv("LLM_PROVIDER"); v != "" {
+		cfg.Provider = strings.ToLower(v)
+	}
+	if v := os.Getenv("LLM_BASE_URL"); v != "" {
+		cfg.BaseURL = v
+	}
+	if v := os.Getenv("LLM_MODEL"); v != "" {
+		cfg.Model = v
+	}
+	if v := os.Getenv("LLM_FALLBACK_MODELS"); v != "" {
+		cfg.FallbackModels = splitList(v)
+	}
+	if v := os.Getenv("LLM_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid LLM_TIMEOUT: %v", err)
+		}
+		cfg.Timeout = Duration(d)
+	}
+	if v := os.Getenv("CACHE_URL"); v != "" {
+		cfg.Cache.URL = v
+	}
+	if v := os.Getenv("LLM_MAX_CONCURRENT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid LLM_MAX_CONCURRENT: %q", v)
+		}
+		cfg.Limiter.MaxConcurrent = n
+	}
+	if v := os.Getenv("LLM_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("invalid LLM_MAX_ATTEMPTS: %q", v)
+		}
+		cfg.Retry.MaxAttempts = n
+	}
+
+	cfg.APIKey = os.Getenv("LLM_API_KEY")
+	if cfg.APIKey == "" {
+		cfg.APIKey = providerAPIKey(cfg.Provider)
+	}
+
+	return cfg, nil
+}
+
+// providerAPIKey reads the provider-specific key variable.
+func providerAPIKey(provider string) string {
+	switch provider {
+	case "gemini":
+		return os.Getenv("GEMINI_API_KEY")
+	case "openai":
+		return os.Getenv("OPENAI_API_KEY")
+	}
+	return ""
+}
+
+// modelsFor returns the ordered model chain for a request. An explicit
+// Request.Model disables fallback; otherwise the endpoint's model and
+// fallbacks win over the global ones.
+func (c Config) modelsFor(req *Request) []string {
+	if req.Model != "" {
+		return []string{req.Model}
+	}
+
+	primary, fallbacks := c.Model, c.FallbackModels
+	if ep, ok := c.Endpoints[req.Endpoint]; ok {
+		if ep.Model != "" {
+			primary = ep.Model
+		}
+		if ep.FallbackModels != nil {
+			fallbacks = ep.FallbackModels
+		}
+	}
+
+	chain := []string{primary}
+	for _, m := range fallbacks {
+		dup := false
+		for _, seen := range chain {
+			dup = dup || seen == m
+		}
+		if !dup && m != "" {
+			chain = append(chain, m)
+		}
+	}
+	return chain
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// validate catches configuration mistakes at startup instead of on the
+// first request that hits them.
+func (c Config) validate() error {
+	for name, ep := range c.Endpoints {
+		if ep.SafetyProfile != "" {
+			if _, ok := c.SafetyProfiles[ep.SafetyProfile]; !ok {
+				return fmt.Errorf("endpoint %q uses unknown safety profile %q", name, ep.SafetyProfile)
+			}
+		}
+		if ep.Persona != "" {
+			if _, ok := c.Personas[ep.Persona]; !ok {
+				return fmt.Errorf("endpoint %q uses unknown persona %q", name, ep.Persona)
+			}
+		}
+	}
+	return nil
+}
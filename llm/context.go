@@ -0,0 +1,47 @@
+package llm
+
+import "unicode/utf8"
+
+// defaultContextWindows lists input token limits for the models this
+// service is usually pointed at.
+func defaultContextWindows() map[string]int {
+	return map[string]int{
+		"gemini-2.0-flash": 1048576,
+		"gemini-1.5-flash": 1048576,
+		"gpt-4o-mini":      128000,
+		"llama3.1":         8192,
+	}
+}
+
+// fallbackContextWindow is assumed for models missing from the table.
+const fallbackContextWindow = 32768
+
+// ContextWindow returns the smallest context window across the endpoint's
+// model chain, so a conversation that fits also fits after a fallback.
+func (c *Client) ContextWindow(endpoint string) int {
+	window := 0
+	for _, model := range c.cfg.modelsFor(&Request{Endpoint: endpoint}) {
+		w, ok := c.cfg.ContextWindows[model]
+		if !ok {
+			w = fallbackContextWindow
+		}
+		if window == 0 || w < window {
+			window = w
+		}
+	}
+	return window
+}
+
+// EstimateTokens approximates the token count of contents at four
+// characters per token plus a small per-message overhead. It is only used
+// to decide when history must be trimmed, so erring high is fine.
+func EstimateTokens(contents []Content) int {
+	tokens := 0
+	for _, c := range contents {
+		tokens += 4
+		for _, p := range c.Parts {
+			tokens += (utf8.RuneCountInString(p.Text) + 3) / 4
+		}
+	}
+	return tokens
+}
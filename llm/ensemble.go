@@ -0,0 +1,103 @@
+package llm
+
+import "fmt"
+
+// EnsembleConfig controls analyses that sample several models at once and
+// vote on the result.
+type EnsembleConfig struct {
+	// Samples is the number of samples per member when the caller does
+	// not choose.
+	Samples int `json:"samples"`
+	// MaxCalls caps members × samples for a single request.
+	MaxCalls int `json:"max_calls"`
+	// MinAgreement is the share of samples that must agree before the
+	// vote counts as a consensus.
+	MinAgreement float64 `json:"min_agreement"`
+	// Members lists what is sampled. Without members every sample goes
+	// through the client's own model chain.
+	Members []EnsembleMember `json:"members"`
+}
+
+// EnsembleMember is a provider and model to sample. Empty fields inherit
+// from the main configuration; the API key of another provider is read
+// from GEMINI_API_KEY or OPENAI_API_KEY.
+type EnsembleMember struct {
+	Provider string `json:"provider,omitempty"`
+	BaseURL  string `json:"base_url,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// Member is a resolved ensemble member. Requests sent through Client with
+// Model set use exactly that model; an empty Model uses the endpoint's
+// fallback chain.
+type Member struct {
+	Name   string
+	Client *Client
+	Model  string
+}
+
+// EnsembleMembers resolves Config.Ensemble.Members into clients. Members on
+// the main provider share this client; others get their own client that
+// records usage into the same tracker.
+func (c *Client) EnsembleMembers() ([]Member, error) {
+	c.membersOnce.Do(func() {
+		if len(c.cfg.Ensemble.Members) == 0 {
+			c.members = []Member{{Name: c.cfg.Provider, Client: c}}
+			return
+		}
+		for _, m := range c.cfg.Ensemble.Members {
+			member, err := c.ensembleMember(m)
+			if err != nil {
+				c.membersErr = err
+				return
+			}
+			c.members = append(c.members, member)
+		}
+	})
+	return c.members, c.membersErr
+}
+
+func (c *Client) ensembleMember(m EnsembleMember) (Member, error) {
+	if (m.Provider == "" || m.Provider == c.cfg.Provider) && m.BaseURL == "" {
+		name := c.cfg.Provider
+		if m.Model != "" {
+			name += "/" + m.Model
+		}
+		return Member{Name: name, Client: c, Model: m.Model}, nil
+	}
+
+	cfg := c.cfg
+	if m.Provider != "" && m.Provider != c.cfg.Provider {
+		cfg.Provider = m.Provider
+		cfg.APIKey = providerAPIKey(m.Provider)
+		cfg.BaseURL = ""
+		cfg.Model = ""
+		cfg.FallbackModels = nil
+	}
+	if m.BaseURL != "" {
+		cfg.BaseURL = m.BaseURL
+	}
+	if m.Model != "" {
+		cfg.Model = m.Model
+	}
+	cfg.Ensemble.Members = nil
+	// applyProviderDefaults writes into Endpoints, which must not leak
+	// back into the main configuration.
+	cfg.Endpoints = make(map[string]EndpointConfig, len(c.cfg.Endpoints))
+	for name, ep := range c.cfg.Endpoints {
+		cfg.Endpoints[name] = ep
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		return Member{}, fmt.Errorf("failed to create ensemble member %s: %v", m.Provider, err)
+	}
+	client.usage = c.usage
+	client.cache = c.cache
+	client.limiter = c.limiter
+	return Member{
+		Name:   client.cfg.Provider + "/" + client.cfg.Model,
+		Client: client,
+		Model:  client.cfg.Model,
+	}, nil
+}
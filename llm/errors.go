@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoContent is returned when the upstream answered successfully but the
+// response carried no text.
+var ErrNoContent = errors.New("no content received from model")
+
+// Error codes returned to API clients alongside the message, so the
+// frontend can tell failures apart without parsing text.
+const (
+	CodePromptBlocked       = "PROMPT_BLOCKED"
+	CodeResponseBlocked     = "RESPONSE_BLOCKED"
+	CodeEmptyResponse       = "EMPTY_RESPONSE"
+	CodeRateLimited         = "UPSTREAM_RATE_LIMITED"
+	CodeUpstreamUnavailable = "UPSTREAM_UNAVAILABLE"
+	CodeUpstreamError       = "UPSTREAM_ERROR"
+	CodeInvalidGeneration   = "INVALID_GENERATION"
+	CodeServerBusy          = "SERVER_BUSY"
+	CodeInternal            = "INTERNAL_ERROR"
+)
+
+// BlockedError is returned when the provider's safety filters refused the
+// prompt (CodePromptBlocked) or withheld the answer (CodeResponseBlocked).
+type BlockedError struct {
+	Code          string
+	Reason        string
+	SafetyRatings []SafetyRating
+}
+
+func (e *BlockedError) Error() string {
+	if e.Code == CodePromptBlocked {
+		return fmt.Sprintf("prompt was blocked by the model (reason: %s)", e.Reason)
+	}
+	return fmt.Sprintf("response was blocked by the model (reason: %s)", e.Reason)
+}
+
+// APIError is a non-2xx answer from an upstream provider.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay requested by the upstream, from the
+	// Retry-After header or a provider-specific hint. Zero if none.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("error from %s API (status %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the same request may succeed later.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// ExhaustedError is returned once every retry attempt has failed with a
+// retryable error.
+type ExhaustedError struct {
+	Attempts int
+	Err      error
+	// RetryAfter is the hint passed on to our own clients.
+	RetryAfter time.Duration
+}
+
+func (e *ExhaustedError) Error() string {
+	if e.Attempts == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("upstream unavailable after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *ExhaustedError) Unwrap() error { return e.Err }
+
+// IsRetryable reports whether err is a transient upstream failure: a
+// retryable status code or a transport error such as a timeout.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// HTTPStatus maps an error from Client to the status our endpoints return:
+// 429 when the upstream rate limit is exhausted, 503 for other transient
+// failures and a full local queue, 422 for content the model refused, 502 for upstream rejections
+// and 500 for everything else.
+func HTTPStatus(err error) int {
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		return http.StatusUnprocessableEntity
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, ErrNoContent) {
+		return http.StatusBadGateway
+	}
+	var queueErr *QueueError
+	if errors.As(err, &queueErr) {
+		return http.StatusServiceUnavailable
+	}
+
+	var apiErr *APIError
+	isAPIErr := errors.As(err, &apiErr)
+
+	var exhausted *ExhaustedError
+	if errors.As(err, &exhausted) || IsRetryable(err) {
+		if isAPIErr && apiErr.StatusCode == http.StatusTooManyRequests {
+			return http.StatusTooManyRequests
+		}
+		return http.StatusServiceUnavailable
+	}
+	if isAPIErr {
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorCode returns the machine-readable code for an error from Client.
+func ErrorCode(err error) string {
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		return blocked.Code
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		return CodeInvalidGeneration
+	}
+	if errors.Is(err, ErrNoContent) {
+		return CodeEmptyResponse
+	}
+	var queueErr *QueueError
+	if errors.As(err, &queueErr) {
+		return CodeServerBusy
+	}
+
+	switch HTTPStatus(err) {
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUpstreamUnavailable
+	case http.StatusBadGateway:
+		return CodeUpstreamError
+	}
+	return CodeInternal
+}
+
+// RetryAfterSeconds returns the retry hint for err in whole seconds, or 0
+// when retrying is pointless.
+func RetryAfterSeconds(err error) int {
+	var d time.Duration
+
+	var exhausted *ExhaustedError
+	var apiErr *APIError
+	var queueErr *QueueError
+	switch {
+	case errors.As(err, &queueErr):
+		d = queueErr.RetryAfter
+	case errors.As(err, &exhausted):
+		d = exhausted.RetryAfter
+	case errors.As(err, &apiErr) && apiErr.Retryable():
+		d = apiErr.RetryAfter
+	}
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+// parseRetryAfter reads a Retry-After header in either delta-seconds or
+// HTTP-date form.
+func parseRetryAfter(h http.Header) time.Duration {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package llm_test
+
+import (
+	"net/http"
+	"testing"
+
+	"stock-analysis-api/llm"
+	"stock-analysis-api/llm/llmtest"
+)
+
+func TestFinishReasons(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		replies    []llmtest.Reply
+		wantText   string
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "blocked prompt",
+			replies:    []llmtest.Reply{{BlockReason: "SAFETY"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   llm.CodePromptBlocked,
+		},
+		{
+			name:       "blocked response",
+			replies:    []llmtest.Reply{{FinishReason: llm.FinishSafety}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   llm.CodeResponseBlocked,
+		},
+		{
+			name:       "recitation",
+			replies:    []llmtest.Reply{{FinishReason: "RECITATION"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   llm.CodeResponseBlocked,
+		},
+		{
+			name:       "empty answer",
+			replies:    []llmtest.Reply{{}},
+			wantStatus: http.StatusBadGateway,
+			wantCode:   llm.CodeEmptyResponse,
+		},
+		{
+			name:       "empty answer with other reason",
+			replies:    []llmtest.Reply{{FinishReason: "OTHER"}},
+			wantStatus: http.StatusBadGateway,
+			wantCode:   llm.CodeEmptyResponse,
+		},
+		{
+			name:       "OpenAI content filter",
+			provider:   "openai",
+			replies:    []llmtest.Reply{{FinishReason: llm.FinishSafety}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   llm.CodeResponseBlocked,
+		},
+		{
+			name: "MAX_TOKENS is continued",
+			replies: []llmtest.Reply{
+				{Text: "part one ", FinishReason: llm.FinishMaxTokens},
+				{Text: "part two"},
+			},
+			wantText: "part one part two",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.NewServer(tt.replies...)
+			defer srv.Close()
+			client := newClient(t, srv, func(cfg *llm.Config) {
+				if tt.provider == "openai" {
+					*cfg = srv.OpenAIConfig()
+				}
+			})
+
+			resp, err := generate(client)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("Generate: %v", err)
+				}
+				if resp.Text != tt.wantText {
+					t.Errorf("text = %q, want %q", resp.Text, tt.wantText)
+				}
+				if resp.FinishReason != llm.FinishStop || resp.Truncated {
+					t.Errorf("finish = %q truncated = %v, want STOP and not truncated", resp.FinishReason, resp.Truncated)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Generate succeeded, want an error")
+			}
+			if got := llm.HTTPStatus(err); got != tt.wantStatus {
+				t.Errorf("HTTPStatus = %d, want %d", got, tt.wantStatus)
+			}
+			if got := llm.ErrorCode(err); got != tt.wantCode {
+				t.Errorf("ErrorCode = %q, want %q", got, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Gemini talks to the Generative Language API (generateContent).
+type Gemini struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewGemini(baseURL, apiKey string, httpClient *http.Client) *Gemini {
+	return &Gemini{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: httpClient,
+	}
+}
+
+func (g *Gemini) Name() string { return "gemini" }
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content       Content        `json:"content"`
+		FinishReason  string         `json:"finishReason"`
+		SafetyRatings []SafetyRating `json:"safetyRatings"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason   string         `json:"blockReason"`
+		SafetyRatings []SafetyRating `json:"safetyRatings"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+	ModelVersion string `json:"modelVersion"`
+}
+
+func (r *geminiResponse) usage() Usage {
+	return Usage{
+		PromptTokens: r.UsageMetadata.PromptTokenCount,
+		OutputTokens: r.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:  r.UsageMetadata.TotalTokenCount,
+	}
+}
+
+type geminiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type       string `json:"@type"`
+			RetryDelay string `json:"retryDelay"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (g *Gemini) Generate(ctx context.Context, req *Request) (*Response, error) {
+	jsonBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request body: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent", g.baseURL, req.Model)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-goog-api-key", g.apiKey)
+
+	resp, err := g.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, g.apiError(resp)
+	}
+
+	var geminiResp geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse Gemini response: %v", err)
+	}
+
+	if err := geminiResp.promptBlocked(); err != nil {
+		return nil, err
+	}
+	if len(geminiResp.Candidates) == 0 {
+		return nil, ErrNoContent
+	}
+	candidate := geminiResp.Candidates[0]
+	text := joinText(candidate.Content.Parts)
+	calls := functionCalls(candidate.Content.Parts)
+	if text == "" && len(calls) == 0 {
+		return nil, emptyCandidateError(candidate.FinishReason, candidate.SafetyRatings)
+	}
+
+	return &Response{
+		Text:          text,
+		FunctionCalls: calls,
+		Provider:      g.Name(),
+		Model:         req.Model,
+		FinishReason:  candidate.FinishReason,
+		SafetyRatings: candidate.SafetyRatings,
+		Usage:         geminiResp.usage(),
+	}, nil
+}
+
+// promptBlocked returns a BlockedError when Gemini refused the prompt
+// itself, in which case there are no candidates at all.
+func (r *geminiResponse) promptBlocked() error {
+	if r.PromptFeedback.BlockReason == "" {
+		return nil
+	}
+	return &BlockedError{
+		Code:          CodePromptBlocked,
+		Reason:        r.PromptFeedback.BlockReason,
+		SafetyRatings: r.PromptFeedback.SafetyRatings,
+	}
+}
+
+// emptyCandidateError explains a candidate without text using its finish
+// reason.
+func emptyCandidateError(finishReason string, ratings []SafetyRating) error {
+	switch finishReason {
+	case FinishSafety, "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return &BlockedError{Code: CodeResponseBlocked, Reason: finishReason, SafetyRatings: ratings}
+	case "", FinishStop:
+		return ErrNoContent
+	default:
+		return fmt.Errorf("%w (finish reason %s)", ErrNoContent, finishReason)
+	}
+}
+
+func (g *Gemini) apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	msg := strings.TrimSpace(string(body))
+	retryAfter := parseRetryAfter(resp.Header)
+
+	var ge geminiError
+	if json.Unmarshal(body, &ge) == nil && ge.Error.Message != "" {
+		msg = ge.Error.Message
+		// Quota errors carry a google.rpc.RetryInfo detail instead of a
+		// Retry-After header.
+		for _, d := range ge.Error.Details {
+			if !strings.HasSuffix(d.Type, "google.rpc.RetryInfo") {
+				continue
+			}
+			if delay, err := time.ParseDuration(d.RetryDelay); err == nil && delay > retryAfter {
+				retryAfter = delay
+			}
+		}
+	}
+	return &APIError{
+		Provider:   "Gemini",
+		StatusCode: resp.StatusCode,
+		Message:    msg,
+		RetryAfter: retryAfter,
+	}
+}
+
+func functionCalls(parts []Part) []FunctionCall {
+	var calls []FunctionCall
+	for _, p := range parts {
+		if p.FunctionCall != nil {
+			calls = append(calls, *p.FunctionCall)
+		}
+	}
+	return calls
+}
+
+// joinText concatenates the text parts of a candidate. Gemini may split a
+// single answer across several parts.
+func joinText(parts []Part) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		sb.WriteString(p.Text)
+	}
+	return sb.String()
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Stream uses streamGenerateContent with alt=sse, where every "data:" line
+// is a complete generateContent response holding the next slice of text.
+func (g *Gemini) Stream(ctx context.Context, req *Request, onChunk ChunkFunc) (*Response, error) {
+	jsonBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request body: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/v1beta/models/%s:streamGenerateContent?alt=sse", g.baseURL, req.Model)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+	httpReq.Header.Set("X-goog-api-key", g.apiKey)
+
+	resp, err := g.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, g.apiError(resp)
+	}
+
+	var full strings.Builder
+	var finishReason string
+	var ratings []SafetyRating
+	var calls []FunctionCall
+	var usage Usage
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to parse Gemini stream chunk: %v", err)
+		}
+		// Token counts are cumulative; the last chunk has the totals.
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			usage = chunk.usage()
+		}
+		if err := chunk.promptBlocked(); err != nil {
+			return nil, err
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+
+		candidate := chunk.Candidates[0]
+		if candidate.FinishReason != "" {
+			finishReason = candidate.FinishReason
+		}
+		if len(candidate.SafetyRatings) > 0 {
+			ratings = candidate.SafetyRatings
+		}
+		calls = append(calls, functionCalls(candidate.Content.Parts)...)
+		text := joinText(candidate.Content.Parts)
+		if text == "" {
+			continue
+		}
+		full.WriteString(text)
+		if err := onChunk(text); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Gemini stream: %w", err)
+	}
+
+	if full.Len() == 0 && len(calls) == 0 {
+		return nil, emptyCandidateError(finishReason, ratings)
+	}
+
+	return &Response{
+		Text:          full.String(),
+		FunctionCalls: calls,
+		Provider:      g.Name(),
+		Model:         req.Model,
+		FinishReason:  finishReason,
+		SafetyRatings: ratings,
+		Usage:         usage,
+	}, nil
+}
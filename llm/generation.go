@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GenerationParams are the caller-supplied generation settings accepted by
+// the public endpoints. Unset fields fall back to the endpoint defaults.
+type GenerationParams struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopK            *int     `json:"top_k,omitempty"`
+	TopP            *float64 `json:"top_p,omitempty"`
+	MaxOutputTokens *int     `json:"max_output_tokens,omitempty"`
+}
+
+// FloatRange and IntRange are inclusive bounds.
+type FloatRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+type IntRange struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// GenerationLimits are the admin-configured bounds that caller-supplied
+// GenerationParams must respect.
+type GenerationLimits struct {
+	Temperature     FloatRange `json:"temperature"`
+	TopK            IntRange   `json:"top_k"`
+	TopP            FloatRange `json:"top_p"`
+	MaxOutputTokens IntRange   `json:"max_output_tokens"`
+}
+
+// ValidationError lists every generation setting that is out of bounds.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid generation settings: " + strings.Join(e.Problems, "; ")
+}
+
+// ResolveGeneration merges caller params over the endpoint defaults and
+// checks the caller-supplied values against the limits for that endpoint.
+// Defaults themselves are trusted and not validated.
+func (c *Client) ResolveGeneration(endpoint string, params *GenerationParams) (*GenerationConfig, error) {
+	ep := c.cfg.Endpoints[endpoint]
+
+	limits := c.cfg.GenerationLimits
+	if ep.GenerationLimits != nil {
+		limits = *ep.GenerationLimits
+	}
+
+	gc := ep.Generation
+	if params == nil {
+		return &gc, nil
+	}
+
+	var problems []string
+	if v := params.Temperature; v != nil {
+		if *v < limits.Temperature.Min || *v > limits.Temperature.Max {
+			problems = append(problems, fmt.Sprintf("temperature must be between %g and %g", limits.Temperature.Min, limits.Temperature.Max))
+		}
+		gc.Temperature = v
+	}
+	if v := params.TopK; v != nil {
+		if *v < limits.TopK.Min || *v > limits.TopK.Max {
+			problems = append(problems, fmt.Sprintf("top_k must be between %d and %d", limits.TopK.Min, limits.TopK.Max))
+		}
+		gc.TopK = v
+	}
+	if v := params.TopP; v != nil {
+		if *v < limits.TopP.Min || *v > limits.TopP.Max {
+			problems = append(problems, fmt.Sprintf("top_p must be between %g and %g", limits.TopP.Min, limits.TopP.Max))
+		}
+		gc.TopP = v
+	}
+	if v := params.MaxOutputTokens; v != nil {
+		if *v < limits.MaxOutputTokens.Min || *v > limits.MaxOutputTokens.Max {
+			problems = append(problems, fmt.Sprintf("max_output_tokens must be between %d and %d", limits.MaxOutputTokens.Min, limits.MaxOutputTokens.Max))
+		}
+		gc.MaxOutputTokens = v
+	}
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return &gc, nil
+}
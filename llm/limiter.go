@@ -0,0 +1,297 @@
+package llm
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"stock-analysis-api/cache"
+)
+
+// LimiterConfig bounds concurrent upstream calls across all endpoints.
+type LimiterConfig struct {
+	// MaxConcurrent is the number of upstream calls in flight; 0 disables
+	// the limiter.
+	MaxConcurrent int `json:"max_concurrent"`
+	// MaxQueue is the number of calls allowed to wait for a slot. Beyond
+	// it, calls fail at once with ErrQueueFull.
+	MaxQueue int `json:"max_queue"`
+	// MaxWait is the longest a call waits in the queue.
+	MaxWait Duration `json:"max_wait"`
+}
+
+// ErrQueueFull is returned when too many calls are already waiting, and
+// ErrQueueTimeout when a call waited longer than LimiterConfig.MaxWait.
+var (
+	ErrQueueFull    = errors.New("too many requests are waiting for the model")
+	ErrQueueTimeout = errors.New("timed out waiting for a free model slot")
+)
+
+// QueueError carries ErrQueueFull or ErrQueueTimeout with a retry hint.
+type QueueError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *QueueError) Error() string { return e.Err.Error() }
+
+func (e *QueueError) Unwrap() error { return e.Err }
+
+// queueRetryAfter is the hint given to clients turned away by the limiter.
+const queueRetryAfter = 2 * time.Second
+
+// QueueStats is the queue time of one endpoint.
+type QueueStats struct {
+	Endpoint string `json:"endpoint"`
+	Priority int    `json:"priority"`
+	Admitted int    `json:"admitted"`
+	// Queued counts the admitted calls that had to wait.
+	Queued   int `json:"queued"`
+	Rejected int `json:"rejected"`
+	TimedOut int `json:"timed_out"`
+	// Wait times are in milliseconds over the admitted calls.
+	AvgWaitMs float64 `json:"avg_wait_ms"`
+	MaxWaitMs float64 `json:"max_wait_ms"`
+
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// LimiterStats is a snapshot of the limiter. With a shared limiter Active
+// and Waiting cover every instance, while Endpoints counts the calls of the
+// reporting instance only.
+type LimiterStats struct {
+	Shared        bool         `json:"shared"`
+	MaxConcurrent int          `json:"max_concurrent"`
+	MaxQueue      int          `json:"max_queue"`
+	Active        int          `json:"active"`
+	Waiting       int          `json:"waiting"`
+	Endpoints     []QueueStats `json:"endpoints"`
+}
+
+// limiter admits at most MaxConcurrent calls. Waiting calls are served by
+// priority, then in arrival order. Without redis the slots and the queue
+// belong to the process; with it they are shared by every instance (see
+// acquireShared).
+type limiter struct {
+	cfg   LimiterConfig
+	redis *cache.Redis
+	// lease bounds how long a shared slot is held by an instance that
+	// died without releasing it.
+	lease time.Duration
+
+	mu     sync.Mutex
+	active int
+	queue  waitQueue
+	seq    int
+	stats  map[string]*QueueStats
+}
+
+type waiter struct {
+	priority int
+	seq      int
+	ready    chan struct{}
+	index    int
+}
+
+func newLimiter(cfg LimiterConfig) *limiter {
+	return &limiter{cfg: cfg, stats: make(map[string]*QueueStats)}
+}
+
+// newSharedLimiter keeps slots and queue in Redis, so MaxConcurrent and the
+// endpoint priorities hold across all instances.
+func newSharedLimiter(cfg LimiterConfig, r *cache.Redis, lease time.Duration) *limiter {
+	l := newLimiter(cfg)
+	l.redis = r
+	l.lease = lease
+	return l
+}
+
+// acquire waits for a slot and returns the function that frees it.
+func (l *limiter) acquire(ctx context.Context, endpoint string, priority int) (func(), error) {
+	if l.cfg.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+	if l.redis != nil {
+		return l.acquireShared(ctx, endpoint, priority)
+	}
+	start := time.Now()
+
+	l.mu.Lock()
+	st := l.statsFor(endpoint, priority)
+	if l.active < l.cfg.MaxConcurrent && l.queue.Len() == 0 {
+		l.active++
+		st.Admitted++
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	if l.queue.Len() >= l.cfg.MaxQueue {
+		st.Rejected++
+		l.mu.Unlock()
+		return nil, &QueueError{Err: ErrQueueFull, RetryAfter: queueRetryAfter}
+	}
+	l.seq++
+	w := &waiter{priority: priority, seq: l.seq, ready: make(chan struct{})}
+	heap.Push(&l.queue, w)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if d := time.Duration(l.cfg.MaxWait); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		l.admitted(st, time.Since(start), true)
+		return l.release, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = &QueueError{Err: ErrQueueTimeout, RetryAfter: queueRetryAfter}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.index < 0 {
+		// The slot was handed over while we gave up; pass it on.
+		l.active--
+		l.handOff()
+	} else {
+		heap.Remove(&l.queue, w.index)
+	}
+	if errors.Is(err, ErrQueueTimeout) {
+		st.TimedOut++
+	}
+	return nil, err
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.handOff()
+}
+
+// handOff gives free slots to the front of the queue. Callers hold mu.
+func (l *limiter) handOff() {
+	for l.active < l.cfg.MaxConcurrent && l.queue.Len() > 0 {
+		w := heap.Pop(&l.queue).(*waiter)
+		l.active++
+		close(w.ready)
+	}
+}
+
+// admitted books a call that got a slot after waiting wait.
+func (l *limiter) admitted(st *QueueStats, wait time.Duration, queued bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	st.Admitted++
+	if !queued {
+		return
+	}
+	st.Queued++
+	st.totalWait += wait
+	if wait > st.maxWait {
+		st.maxWait = wait
+	}
+}
+
+func (l *limiter) statsFor(endpoint string, priority int) *QueueStats {
+	st, ok := l.stats[endpoint]
+	if !ok {
+		st = &QueueStats{Endpoint: endpoint, Priority: priority}
+		l.stats[endpoint] = st
+	}
+	return st
+}
+
+func (l *limiter) snapshot(ctx context.Context) (LimiterStats, error) {
+	out := LimiterStats{
+		Shared:        l.redis != nil,
+		MaxConcurrent: l.cfg.MaxConcurrent,
+		MaxQueue:      l.cfg.MaxQueue,
+		Endpoints:     []QueueStats{},
+	}
+	if l.redis != nil {
+		var err error
+		if out.Active, out.Waiting, err = l.sharedCounts(ctx); err != nil {
+			return LimiterStats{}, err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.redis == nil {
+		out.Active = l.active
+		out.Waiting = l.queue.Len()
+	}
+	for _, st := range l.stats {
+		s := *st
+		if s.Admitted > 0 {
+			s.AvgWaitMs = float64(s.totalWait.Microseconds()) / 1000 / float64(s.Admitted)
+		}
+		s.MaxWaitMs = float64(s.maxWait.Microseconds()) / 1000
+		out.Endpoints = append(out.Endpoints, s)
+	}
+	sort.Slice(out.Endpoints, func(i, j int) bool {
+		return out.Endpoints[i].Endpoint < out.Endpoints[j].Endpoint
+	})
+	return out, nil
+}
+
+// waitQueue is a heap of waiters, highest priority first.
+type waitQueue []*waiter
+
+func (q waitQueue) Len() int { return len(q) }
+
+func (q waitQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q waitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *waitQueue) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *waitQueue) Pop() interface{} {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// LimiterStats returns the current limiter state and queue times per
+// endpoint since the instance started.
+func (c *Client) LimiterStats(ctx context.Context) (LimiterStats, error) {
+	return c.limiter.snapshot(ctx)
+}
+
+// limited runs call once a limiter slot is free, using the priority of
+// req's endpoint.
+func (c *Client) limited(ctx context.Context, req *Request, call func() (*Response, error)) (*Response, error) {
+	release, err := c.limiter.acquire(ctx, req.Endpoint, c.cfg.Endpoints[req.Endpoint].Priority)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire model slot: %w", err)
+	}
+	defer release()
+	return call()
+}
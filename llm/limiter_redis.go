@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Redis keys of the shared limiter: leases by expiry, waiters by priority
+// and arrival, and the last time each waiter polled.
+const (
+	limiterActiveKey = "llm:limiter:active"
+	limiterQueueKey  = "llm:limiter:queue"
+	limiterSeenKey   = "llm:limiter:seen"
+)
+
+const (
+	// limiterPoll is how often a waiting call asks for a slot.
+	limiterPoll = 200 * time.Millisecond
+	// limiterSeen is how long a waiter stays queued without polling, so
+	// one whose instance died stops holding back those behind it.
+	limiterSeen = 5 * limiterPoll
+)
+
+// acquireSlot admits a waiter when fewer waiters are ahead of it than there
+// are free slots. It returns 1 when admitted, 0 when queued and -1 when the
+// queue is full. Waiters are ordered by priority, then arrival, using the
+// server clock so instances need not agree on the time.
+const acquireSlot = `
+local active, queue, seen = KEYS[1], KEYS[2], KEYS[3]
+local token, max, maxQueue = ARGV[1], tonumber(ARGV[2]), tonumber(ARGV[3])
+local priority, lease, ttl = tonumber(ARGV[4]), tonumber(ARGV[5]), tonumber(ARGV[6])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local function ms(n) return string.format('%.0f', n) end
+
+redis.call('ZREMRANGEBYSCORE', active, '-inf', ms(now))
+for _, stale in ipairs(redis.call('ZRANGEBYSCORE', seen, '-inf', ms(now))) do
+  redis.call('ZREM', queue, stale)
+end
+redis.call('ZREMRANGEBYSCORE', seen, '-inf', ms(now))
+
+local free = max - redis.call('ZCARD', active)
+local rank = redis.call('ZRANK', queue, token)
+local ahead = rank or redis.call('ZCARD', queue)
+if ahead < free then
+  redis.call('ZREM', queue, token)
+  redis.call('ZREM', seen, token)
+  redis.call('ZADD', active, ms(now + lease), token)
+  return 1
+end
+if not rank then
+  if redis.call('ZCARD', queue) >= maxQueue then
+    return -1
+  end
+  redis.call('ZADD', queue, ms(-priority * 1e13 + now), token)
+end
+redis.call('ZADD', seen, ms(now + ttl), token)
+return 0`
+
+// releaseSlot frees a slot or leaves the queue.
+const releaseSlot = `
+for _, key in ipairs(KEYS) do
+  redis.call('ZREM', key, ARGV[1])
+end
+return 1`
+
+// acquireShared is acquire against the Redis-backed slots. It polls until a
+// slot is free, MaxWait passes or ctx is done.
+func (l *limiter) acquireShared(ctx context.Context, endpoint string, priority int) (func(), error) {
+	start := time.Now()
+	l.mu.Lock()
+	st := l.statsFor(endpoint, priority)
+	l.mu.Unlock()
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+	release := func() {
+		// A slot that cannot be released lapses with its lease.
+		l.redis.Do(context.Background(), "EVAL", releaseSlot, "3",
+			limiterActiveKey, limiterQueueKey, limiterSeenKey, token)
+	}
+
+	var deadline <-chan time.Time
+	if d := time.Duration(l.cfg.MaxWait); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(limiterPoll)
+	defer ticker.Stop()
+
+	queued := false
+	for {
+		reply, err := l.redis.Do(ctx, "EVAL", acquireSlot, "3",
+			limiterActiveKey, limiterQueueKey, limiterSeenKey,
+			token,
+			strconv.Itoa(l.cfg.MaxConcurrent),
+			strconv.Itoa(l.cfg.MaxQueue),
+			strconv.Itoa(priority),
+			strconv.FormatInt(l.lease.Milliseconds(), 10),
+			strconv.FormatInt(limiterSeen.Milliseconds(), 10))
+		if err != nil {
+			if queued {
+				release()
+			}
+			return nil, fmt.Errorf("failed to reach the shared limiter: %v", err)
+		}
+
+		switch string(reply) {
+		case "1":
+			l.admitted(st, time.Since(start), queued)
+			return release, nil
+		case "-1":
+			l.mu.Lock()
+			st.Rejected++
+			l.mu.Unlock()
+			return nil, &QueueError{Err: ErrQueueFull, RetryAfter: queueRetryAfter}
+		}
+		queued = true
+
+		select {
+		case <-ticker.C:
+			continue
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-deadline:
+			err = &QueueError{Err: ErrQueueTimeout, RetryAfter: queueRetryAfter}
+		}
+		release()
+		if errors.Is(err, ErrQueueTimeout) {
+			l.mu.Lock()
+			st.TimedOut++
+			l.mu.Unlock()
+		}
+		return nil, err
+	}
+}
+
+// sharedCounts returns the slots in use and the calls waiting across all
+// instances. Expired entries may be counted until the next acquire.
+func (l *limiter) sharedCounts(ctx context.Context) (active, waiting int, err error) {
+	for key, dst := range map[string]*int{limiterActiveKey: &active, limiterQueueKey: &waiting} {
+		reply, err := l.redis.Do(ctx, "ZCARD", key)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read the shared limiter: %v", err)
+		}
+		*dst, _ = strconv.Atoi(string(reply))
+	}
+	return active, waiting, nil
+}
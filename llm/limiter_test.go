@@ -0,0 +1,325 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"stock-analysis-api/cache"
+)
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waiting(l *limiter) func() bool {
+	return func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.queue.Len() > 0
+	}
+}
+
+func stats(t *testing.T, l *limiter) LimiterStats {
+	t.Helper()
+	st, err := l.snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	return st
+}
+
+func TestLimiterRelease(t *testing.T) {
+	l := newLimiter(LimiterConfig{MaxConcurrent: 2, MaxQueue: 1, MaxWait: Duration(time.Second)})
+	ctx := context.Background()
+
+	release1, err := l.acquire(ctx, "analyze", 30)
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+	release2, err := l.acquire(ctx, "analyze", 30)
+	if err != nil {
+		t.Fatalf("second acquire: %v", err)
+	}
+
+	admitted := make(chan func())
+	go func() {
+		release, err := l.acquire(ctx, "prompt", 10)
+		if err != nil {
+			t.Errorf("queued acquire: %v", err)
+			close(admitted)
+			return
+		}
+		admitted <- release
+	}()
+	waitFor(t, "the third call to queue", waiting(l))
+
+	if _, err := l.acquire(ctx, "prompt", 10); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("acquire with a full queue = %v, want ErrQueueFull", err)
+	}
+
+	release1()
+	release3 := <-admitted
+	if st := stats(t, l); st.Active != 2 || st.Waiting != 0 {
+		t.Errorf("after hand-off active/waiting = %d/%d, want 2/0", st.Active, st.Waiting)
+	}
+	release2()
+	release3()
+
+	st := stats(t, l)
+	if st.Active != 0 || st.Waiting != 0 {
+		t.Errorf("after release active/waiting = %d/%d, want 0/0", st.Active, st.Waiting)
+	}
+	for _, ep := range st.Endpoints {
+		switch ep.Endpoint {
+		case "analyze":
+			if ep.Admitted != 2 || ep.Queued != 0 {
+				t.Errorf("analyze stats = %+v", ep)
+			}
+		case "prompt":
+			if ep.Admitted != 1 || ep.Queued != 1 || ep.Rejected != 1 {
+				t.Errorf("prompt stats = %+v", ep)
+			}
+		}
+	}
+}
+
+func TestLimiterPriority(t *testing.T) {
+	l := newLimiter(LimiterConfig{MaxConcurrent: 1, MaxQueue: 8, MaxWait: Duration(time.Second)})
+	ctx := context.Background()
+
+	hold, err := l.acquire(ctx, "analyze", 30)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+	queue := func(endpoint string, priority, position int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(ctx, endpoint, priority)
+			if err != nil {
+				t.Errorf("%s: %v", endpoint, err)
+				return
+			}
+			mu.Lock()
+			order = append(order, endpoint)
+			mu.Unlock()
+			release()
+		}()
+		waitFor(t, endpoint+" to queue", func() bool {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			return l.queue.Len() == position
+		})
+	}
+	// Arrival order is the reverse of priority, except for the two
+	// "daily" calls, which keep their arrival order.
+	queue("prompt", 10, 1)
+	queue("daily", 20, 2)
+	queue("daily-2", 20, 3)
+	queue("trade-plan", 30, 4)
+
+	hold()
+	wg.Wait()
+
+	want := []string{"trade-plan", "daily", "daily-2", "prompt"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestLimiterTimeout(t *testing.T) {
+	l := newLimiter(LimiterConfig{MaxConcurrent: 1, MaxQueue: 1, MaxWait: Duration(20 * time.Millisecond)})
+	ctx := context.Background()
+
+	hold, err := l.acquire(ctx, "analyze", 30)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer hold()
+
+	_, err = l.acquire(ctx, "prompt", 10)
+	var qe *QueueError
+	if !errors.As(err, &qe) || !errors.Is(err, ErrQueueTimeout) || qe.RetryAfter <= 0 {
+		t.Fatalf("acquire = %v, want a QueueError with ErrQueueTimeout", err)
+	}
+	st := stats(t, l)
+	if st.Waiting != 0 {
+		t.Errorf("waiting = %d after the timeout, want 0", st.Waiting)
+	}
+	for _, ep := range st.Endpoints {
+		if ep.Endpoint == "prompt" && ep.TimedOut != 1 {
+			t.Errorf("prompt timed out = %d, want 1", ep.TimedOut)
+		}
+	}
+}
+
+// TestLimiterCancelDuringHandOff cancels a waiter while the slot is being
+// handed to it. Whichever way the waiter's select goes, the slot must end
+// up either held by the waiter or passed on, never lost.
+func TestLimiterCancelDuringHandOff(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		l := newLimiter(LimiterConfig{MaxConcurrent: 1, MaxQueue: 1, MaxWait: Duration(time.Second)})
+
+		// The holder's slot is released by hand below.
+		if _, err := l.acquire(context.Background(), "analyze", 30); err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error, 1)
+		go func() {
+			release, err := l.acquire(ctx, "prompt", 10)
+			if err == nil {
+				release()
+			}
+			result <- err
+		}()
+		waitFor(t, "the waiter to queue", waiting(l))
+
+		// Cancel and hand the slot over while the waiter cannot take mu,
+		// so both its ready channel and ctx.Done are ready together.
+		l.mu.Lock()
+		cancel()
+		time.Sleep(time.Millisecond)
+		l.active--
+		l.handOff()
+		l.mu.Unlock()
+
+		if err := <-result; err != nil && !errors.Is(err, context.Canceled) {
+			t.Fatalf("waiter error = %v, want nil or context.Canceled", err)
+		}
+		if st := stats(t, l); st.Active != 0 || st.Waiting != 0 {
+			t.Fatalf("iteration %d: active/waiting = %d/%d, want 0/0", i, st.Active, st.Waiting)
+		}
+		release, err := l.acquire(context.Background(), "analyze", 30)
+		if err != nil {
+			t.Fatalf("acquire after the race: %v", err)
+		}
+		release()
+	}
+}
+
+// testRedis returns the server named by TEST_REDIS_URL with the limiter
+// keys cleared. The keys are fixed, so point it at a scratch database.
+func testRedis(t *testing.T) *cache.Redis {
+	t.Helper()
+	raw := os.Getenv("TEST_REDIS_URL")
+	if raw == "" {
+		t.Skip("TEST_REDIS_URL is not set")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("TEST_REDIS_URL: %v", err)
+	}
+	r, err := cache.NewRedis(u)
+	if err != nil {
+		t.Fatalf("TEST_REDIS_URL: %v", err)
+	}
+	clear := func() {
+		r.Do(context.Background(), "DEL", limiterActiveKey, limiterQueueKey, limiterSeenKey)
+	}
+	clear()
+	t.Cleanup(clear)
+	return r
+}
+
+func TestSharedLimiter(t *testing.T) {
+	r := testRedis(t)
+	cfg := LimiterConfig{MaxConcurrent: 1, MaxQueue: 2, MaxWait: Duration(5 * time.Second)}
+	// Two limiters stand in for two instances sharing the slots.
+	a := newSharedLimiter(cfg, r, time.Minute)
+	b := newSharedLimiter(cfg, r, time.Minute)
+	ctx := context.Background()
+
+	hold, err := a.acquire(ctx, "analyze", 30)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	order := make(chan string, 2)
+	var wg sync.WaitGroup
+	queue := func(l *limiter, endpoint string, priority int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(ctx, endpoint, priority)
+			if err != nil {
+				t.Errorf("%s: %v", endpoint, err)
+				return
+			}
+			order <- endpoint
+			time.Sleep(50 * time.Millisecond)
+			release()
+		}()
+	}
+	queue(b, "prompt", 10)
+	waitFor(t, "prompt to queue", func() bool { return stats(t, a).Waiting == 1 })
+	queue(b, "trade-plan", 30)
+	waitFor(t, "trade-plan to queue", func() bool { return stats(t, a).Waiting == 2 })
+
+	if _, err := a.acquire(ctx, "daily", 20); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("acquire with a full queue = %v, want ErrQueueFull", err)
+	}
+	if st := stats(t, b); !st.Shared || st.Active != 1 {
+		t.Errorf("stats = %+v, want one shared slot in use", st)
+	}
+
+	hold()
+	wg.Wait()
+	close(order)
+	var got []string
+	for endpoint := range order {
+		got = append(got, endpoint)
+	}
+	if len(got) != 2 || got[0] != "trade-plan" || got[1] != "prompt" {
+		t.Errorf("order = %v, want [trade-plan prompt]", got)
+	}
+	if st := stats(t, a); st.Active != 0 || st.Waiting != 0 {
+		t.Errorf("after release active/waiting = %d/%d, want 0/0", st.Active, st.Waiting)
+	}
+}
+
+func TestSharedLimiterLease(t *testing.T) {
+	r := testRedis(t)
+	cfg := LimiterConfig{MaxConcurrent: 1, MaxQueue: 1, MaxWait: Duration(2 * time.Second)}
+	crashed := newSharedLimiter(cfg, r, 300*time.Millisecond)
+	other := newSharedLimiter(cfg, r, time.Minute)
+
+	// The slot is never released, as if the instance had died.
+	if _, err := crashed.acquire(context.Background(), "analyze", 30); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	start := time.Now()
+	release, err := other.acquire(context.Background(), "prompt", 10)
+	if err != nil {
+		t.Fatalf("acquire after the lease: %v", err)
+	}
+	release()
+	if waited := time.Since(start); waited < 200*time.Millisecond {
+		t.Errorf("admitted after %v, before the lease ran out", waited)
+	}
+}
@@ -0,0 +1,159 @@
+// Package llm is the shared client for every AI-backed endpoint. Handlers
+// build a provider-neutral Request and hand it to a Client, which resolves
+// configuration and dispatches to the configured Provider.
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// Role values accepted in Content.Role.
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+)
+
+// Finish reasons, using the Gemini vocabulary. Other providers map their own
+// values onto these.
+const (
+	FinishStop      = "STOP"
+	FinishMaxTokens = "MAX_TOKENS"
+	FinishSafety    = "SAFETY"
+)
+
+// Provider is implemented by every LLM backend.
+type Provider interface {
+	// Name returns the short identifier used in configuration and logs.
+	Name() string
+	// Generate runs a single, non-streaming completion.
+	Generate(ctx context.Context, req *Request) (*Response, error)
+}
+
+// Request is a provider-neutral generation request. The JSON tags follow the
+// Gemini wire format so the common case needs no translation.
+type Request struct {
+	// Endpoint names the calling endpoint (e.g. "analyze") and selects its
+	// entry in Config.Endpoints. It is never sent upstream.
+	Endpoint string `json:"-"`
+	// Model overrides the configured model when set.
+	Model string `json:"-"`
+	// SafetyProfile overrides the endpoint's safety profile. It is ignored
+	// when SafetySettings is set explicitly.
+	SafetyProfile string `json:"-"`
+	// Persona overrides the endpoint's persona. It is ignored when
+	// SystemInstruction is set explicitly.
+	Persona string `json:"-"`
+	// Refresh skips the response cache lookup but still stores the new
+	// answer. NoCache bypasses the cache entirely.
+	Refresh bool `json:"-"`
+	NoCache bool `json:"-"`
+
+	// SystemInstruction carries our own instructions apart from the
+	// user-supplied Contents. Providers without a native field send it as
+	// a system message.
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	Contents          []Content         `json:"contents"`
+	Tools             []Tool            `json:"tools,omitempty"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+	SafetySettings    []SafetySetting   `json:"safetySettings,omitempty"`
+}
+
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+// Part holds exactly one of Text, FunctionCall or FunctionResponse.
+type Part struct {
+	Text             string            `json:"text,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GenerationConfig uses pointers so that an explicit zero (e.g. temperature
+// 0) can be told apart from "not set".
+type GenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopK            *int     `json:"topK,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
+
+	// ResponseMimeType set to MimeJSON asks for JSON output; ResponseSchema
+	// additionally constrains its shape. Providers without schema support
+	// only switch to JSON mode, so the prompt should describe the fields.
+	ResponseMimeType string  `json:"responseMimeType,omitempty"`
+	ResponseSchema   *Schema `json:"responseSchema,omitempty"`
+}
+
+// MimeJSON is the GenerationConfig.ResponseMimeType for JSON output.
+const MimeJSON = "application/json"
+
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// SafetyRating is Gemini's assessment of a prompt or candidate.
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// Response is the normalized result of a generation call.
+type Response struct {
+	Text         string `json:"text"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	// SafetyProfile names the profile the request was sent with.
+	SafetyProfile string         `json:"safety_profile,omitempty"`
+	SafetyRatings []SafetyRating `json:"safety_ratings,omitempty"`
+	// Persona names the system instruction the request was sent with.
+	Persona string `json:"persona,omitempty"`
+	// Continuations counts the follow-up requests made because the model
+	// stopped at MAX_TOKENS. Truncated is set if it still had not finished.
+	Continuations int  `json:"continuations,omitempty"`
+	Truncated     bool `json:"truncated,omitempty"`
+	// FunctionCalls are tool invocations requested instead of text. They
+	// are executed by Client.GenerateWithTools.
+	FunctionCalls []FunctionCall `json:"-"`
+	// ToolCalls lists the tools executed while producing Text.
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// Usage holds token counts as reported by the provider; CostUSD is
+	// filled in by Client from Config.Pricing.
+	Usage Usage `json:"usage"`
+	// Cached is set when the answer came from the response cache, in which
+	// case Usage is zero. CacheExpires is when the cached entry lapses; it
+	// is zero when the endpoint is not cached.
+	Cached       bool      `json:"cached,omitempty"`
+	CacheExpires time.Time `json:"-"`
+}
+
+// UserText wraps a single prompt as a one-turn conversation.
+func UserText(prompt string) []Content {
+	return []Content{{Role: RoleUser, Parts: []Part{{Text: prompt}}}}
+}
+
+// Float returns a pointer to v, for filling GenerationConfig.
+func Float(v float64) *float64 { return &v }
+
+// Int returns a pointer to v, for filling GenerationConfig.
+func Int(v int) *int { return &v }
+
+// Bool returns a pointer to v, for filling EndpointConfig.
+func Bool(v bool) *bool { return &v }
+
+// ChunkFunc receives streamed text as it arrives. Returning an error aborts
+// the stream.
+type ChunkFunc func(text string) error
+
+// StreamProvider is implemented by providers that can stream partial
+// output. Providers without it are streamed as a single chunk by Client.
+type StreamProvider interface {
+	Provider
+	// Stream calls onChunk for each piece of text and returns the complete
+	// response once the upstream is done.
+	Stream(ctx context.Context, req *Request, onChunk ChunkFunc) (*Response, error)
+}
@@ -0,0 +1,248 @@
+// Package llmtest provides a local mock LLM server for tests and offline
+// development. It answers the Gemini generateContent, OpenAI-compatible chat
+// completions and Ollama /api/chat protocols with canned replies.
+package llmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stock-analysis-api/llm"
+)
+
+// Reply is the canned answer returned for one call.
+type Reply struct {
+	Text string
+	// FinishReason is in the Gemini vocabulary and defaults to STOP. The
+	// OpenAI and Ollama replies translate it to their own values.
+	FinishReason string
+	// FunctionCall, when set, makes a Gemini reply ask for that tool
+	// instead of returning text.
+	FunctionCall *llm.FunctionCall
+	// BlockReason, when set, answers like Gemini does for a blocked
+	// prompt: no candidates and promptFeedback.blockReason.
+	BlockReason string
+	// Status, when non-zero and not 200, makes the server return an error
+	// with ErrorMessage as the body message.
+	Status       int
+	ErrorMessage string
+	// RetryAfter, when set on an error reply, is sent as a Retry-After
+	// header in whole seconds.
+	RetryAfter time.Duration
+}
+
+// Call records one request received by the server.
+type Call struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Server is a running mock. Replies are served in order; once exhausted the
+// last reply is repeated.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	replies []Reply
+	calls   []Call
+}
+
+// NewServer starts a mock that serves replies in order.
+func NewServer(replies ...Reply) *Server {
+	s := &Server{replies: replies}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Calls returns a copy of the requests received so far.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// GeminiConfig returns an llm.Config pointing the Gemini provider here.
+func (s *Server) GeminiConfig() llm.Config {
+	cfg := llm.DefaultConfig()
+	cfg.Provider = "gemini"
+	cfg.BaseURL = s.URL
+	cfg.APIKey = "test-key"
+	return cfg
+}
+
+// OllamaConfig returns an llm.Config pointing the Ollama provider here.
+func (s *Server) OllamaConfig() llm.Config {
+	cfg := llm.DefaultConfig()
+	cfg.Provider = "ollama"
+	cfg.BaseURL = s.URL
+	cfg.Model = "mock-model"
+	return cfg
+}
+
+// OpenAIConfig returns an llm.Config pointing the OpenAI provider here.
+func (s *Server) OpenAIConfig() llm.Config {
+	cfg := llm.DefaultConfig()
+	cfg.Provider = "openai"
+	cfg.BaseURL = s.URL + "/v1"
+	cfg.Model = "mock-model"
+	return cfg
+}
+
+func (s *Server) next(r *http.Request) Reply {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+
+	if len(s.replies) == 0 {
+		return Reply{Text: "mock response"}
+	}
+	idx := len(s.calls) - 1
+	if idx >= len(s.replies) {
+		idx = len(s.replies) - 1
+	}
+	return s.replies[idx]
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	reply := s.next(r)
+	w.Header().Set("Content-Type", "application/json")
+
+	if reply.Status != 0 && reply.Status != http.StatusOK {
+		if reply.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(reply.RetryAfter.Seconds())))
+		}
+		w.WriteHeader(reply.Status)
+		if r.URL.Path == "/api/chat" {
+			// Ollama reports errors as a bare message.
+			json.NewEncoder(w).Encode(map[string]string{"error": reply.ErrorMessage})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": reply.Status, "message": reply.ErrorMessage},
+		})
+		return
+	}
+
+	finish := reply.FinishReason
+	if finish == "" {
+		finish = llm.FinishStop
+	}
+
+	switch {
+	case reply.BlockReason != "":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"promptFeedback": map[string]string{"blockReason": reply.BlockReason},
+		})
+	case strings.HasSuffix(r.URL.Path, ":streamGenerateContent"):
+		s.streamGemini(w, reply.Text, finish)
+	case strings.HasSuffix(r.URL.Path, "/chat/completions"):
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model": "mock-model",
+			"choices": []map[string]interface{}{{
+				"message":       map[string]string{"role": "assistant", "content": reply.Text},
+				"finish_reason": openAIFinish(finish),
+			}},
+			"usage": openAIUsage(reply.Text),
+		})
+	case r.URL.Path == "/api/chat":
+		u := mockUsage(reply.Text)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":             "mock-model",
+			"message":           map[string]string{"role": "assistant", "content": reply.Text},
+			"done":              true,
+			"done_reason":       ollamaFinish(finish),
+			"prompt_eval_count": u["promptTokenCount"],
+			"eval_count":        u["candidatesTokenCount"],
+		})
+	case reply.FunctionCall != nil:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"candidates": []map[string]interface{}{{
+				"content":      map[string]interface{}{"role": "model", "parts": []llm.Part{{FunctionCall: reply.FunctionCall}}},
+				"finishReason": finish,
+			}},
+			"usageMetadata": mockUsage(""),
+		})
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"candidates": []map[string]interface{}{{
+				"content":      map[string]interface{}{"role": "model", "parts": []map[string]string{{"text": reply.Text}}},
+				"finishReason": finish,
+			}},
+			"usageMetadata": mockUsage(reply.Text),
+		})
+	}
+}
+
+// streamGemini answers streamGenerateContent?alt=sse, sending the reply one
+// word per event and the finish reason on the last one.
+func (s *Server) streamGemini(w http.ResponseWriter, text, finish string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+
+	words := strings.SplitAfter(text, " ")
+	for i, word := range words {
+		candidate := map[string]interface{}{
+			"content": map[string]interface{}{"role": "model", "parts": []map[string]string{{"text": word}}},
+		}
+		chunk := map[string]interface{}{"candidates": []interface{}{candidate}}
+		if i == len(words)-1 {
+			candidate["finishReason"] = finish
+			chunk["usageMetadata"] = mockUsage(text)
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\r\n\r\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// mockUsage counts words as tokens: ten prompt tokens plus one per word.
+func mockUsage(text string) map[string]int {
+	out := len(strings.Fields(text))
+	return map[string]int{
+		"promptTokenCount":     10,
+		"candidatesTokenCount": out,
+		"totalTokenCount":      10 + out,
+	}
+}
+
+// openAIUsage is mockUsage under the chat completions field names.
+func openAIUsage(text string) map[string]int {
+	u := mockUsage(text)
+	return map[string]int{
+		"prompt_tokens":     u["promptTokenCount"],
+		"completion_tokens": u["candidatesTokenCount"],
+		"total_tokens":      u["totalTokenCount"],
+	}
+}
+
+// ollamaFinish returns the done_reason Ollama would send. Ollama has no
+// content filter, so only the token limit differs from "stop".
+func ollamaFinish(reason string) string {
+	if reason == llm.FinishMaxTokens {
+		return "length"
+	}
+	return "stop"
+}
+
+func openAIFinish(reason string) string {
+	switch reason {
+	case llm.FinishMaxTokens:
+		return "length"
+	case llm.FinishSafety:
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
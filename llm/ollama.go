@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Ollama talks to a locally hosted model through the Ollama /api/chat
+// protocol. Unlike OpenAI-compatible servers it accepts topK, so all
+// GenerationConfig fields are honored.
+type Ollama struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewOllama(baseURL string, httpClient *http.Client) *Ollama {
+	return &Ollama{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+func (o *Ollama) Name() string { return "ollama" }
+
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+	// Format "json" constrains the reply to valid JSON.
+	Format string `json:"format,omitempty"`
+}
+
+type ollamaResponse struct {
+	Model      string        `json:"model"`
+	Message    openAIMessage `json:"message"`
+	Done       bool          `json:"done"`
+	DoneReason string        `json:"done_reason"`
+	// PromptEvalCount and EvalCount are the prompt and output token counts.
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (o *Ollama) Generate(ctx context.Context, req *Request) (*Response, error) {
+	body := ollamaRequest{
+		Model:    req.Model,
+		Messages: openAIMessages(req.SystemInstruction, req.Contents),
+	}
+	if gc := req.GenerationConfig; gc != nil {
+		body.Options = &ollamaOptions{
+			Temperature: gc.Temperature,
+			TopK:        gc.TopK,
+			TopP:        gc.TopP,
+			NumPredict:  gc.MaxOutputTokens,
+		}
+		if gc.ResponseMimeType == MimeJSON {
+			body.Format = "json"
+		}
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request body: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/chat", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.apiError(resp)
+	}
+
+	var oResp ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&oResp); err != nil {
+		return nil, fmt.Errorf("failed to parse Ollama response: %v", err)
+	}
+
+	if oResp.Message.Content == "" {
+		return nil, emptyCandidateError(ollamaFinishReason(oResp.DoneReason), nil)
+	}
+
+	model := oResp.Model
+	if model == "" {
+		model = req.Model
+	}
+
+	return &Response{
+		Text:         oResp.Message.Content,
+		Provider:     o.Name(),
+		Model:        model,
+		FinishReason: ollamaFinishReason(oResp.DoneReason),
+		Usage: Usage{
+			PromptTokens: oResp.PromptEvalCount,
+			OutputTokens: oResp.EvalCount,
+			TotalTokens:  oResp.PromptEvalCount + oResp.EvalCount,
+		},
+	}, nil
+}
+
+func (o *Ollama) apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	// Ollama reports errors as {"error": "message"}.
+	msg := strings.TrimSpace(string(body))
+	var oe struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &oe) == nil && oe.Error != "" {
+		msg = oe.Error
+	}
+	return &APIError{
+		Provider:   "Ollama",
+		StatusCode: resp.StatusCode,
+		Message:    msg,
+		RetryAfter: parseRetryAfter(resp.Header),
+	}
+}
+
+// ollamaFinishReason maps Ollama's done_reason onto the Gemini vocabulary
+// used by Response.FinishReason. Ollama only stops for "stop" or "length";
+// "load" and "unload" answer model management calls and carry no text.
+func ollamaFinishReason(reason string) string {
+	switch reason {
+	case "stop":
+		return FinishStop
+	case "length":
+		return FinishMaxTokens
+	case "":
+		return ""
+	default:
+		return strings.ToUpper(reason)
+	}
+}
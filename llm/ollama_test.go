@@ -0,0 +1,151 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"stock-analysis-api/llm"
+	"stock-analysis-api/llm/llmtest"
+)
+
+// ollamaBody is the part of an /api/chat request the tests check.
+type ollamaBody struct {
+	Model    string `json:"model"`
+	Stream   bool   `json:"stream"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+	Options *struct {
+		Temperature *float64 `json:"temperature"`
+		TopK        *int     `json:"top_k"`
+		NumPredict  *int     `json:"num_predict"`
+	} `json:"options"`
+	Format string `json:"format"`
+}
+
+func TestOllamaRequest(t *testing.T) {
+	srv := llmtest.NewServer(llmtest.Reply{Text: "two words"})
+	defer srv.Close()
+	provider := llm.NewOllama(srv.URL+"/", http.DefaultClient)
+
+	temperature, topK, maxTokens := 0.2, 40, 512
+	resp, err := provider.Generate(context.Background(), &llm.Request{
+		Model:             "llama3.1",
+		SystemInstruction: &llm.Content{Parts: []llm.Part{{Text: "You are an analyst."}}},
+		Contents: []llm.Content{
+			{Role: llm.RoleUser, Parts: []llm.Part{{Text: "Analyze BBRI"}}},
+			{Role: llm.RoleModel, Parts: []llm.Part{{Text: "BUY"}}},
+			{Role: llm.RoleUser, Parts: []llm.Part{{Text: "Why?"}}},
+		},
+		GenerationConfig: &llm.GenerationConfig{
+			Temperature:      &temperature,
+			TopK:             &topK,
+			MaxOutputTokens:  &maxTokens,
+			ResponseMimeType: llm.MimeJSON,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	calls := srv.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("calls = %d, want 1", len(calls))
+	}
+	if calls[0].Path != "/api/chat" {
+		t.Errorf("path = %q, want /api/chat", calls[0].Path)
+	}
+
+	var body ollamaBody
+	if err := json.Unmarshal(calls[0].Body, &body); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if body.Model != "llama3.1" || body.Stream {
+		t.Errorf("model = %q, stream = %v; want llama3.1 without streaming", body.Model, body.Stream)
+	}
+	wantMessages := [][2]string{
+		{"system", "You are an analyst."},
+		{"user", "Analyze BBRI"},
+		{"assistant", "BUY"},
+		{"user", "Why?"},
+	}
+	if len(body.Messages) != len(wantMessages) {
+		t.Fatalf("messages = %+v, want %v", body.Messages, wantMessages)
+	}
+	for i, want := range wantMessages {
+		if got := body.Messages[i]; got.Role != want[0] || got.Content != want[1] {
+			t.Errorf("message %d = %s %q, want %s %q", i, got.Role, got.Content, want[0], want[1])
+		}
+	}
+	if o := body.Options; o == nil ||
+		o.Temperature == nil || *o.Temperature != temperature ||
+		o.TopK == nil || *o.TopK != topK ||
+		o.NumPredict == nil || *o.NumPredict != maxTokens {
+		t.Errorf("options = %+v, want temperature %v, top_k %d, num_predict %d", body.Options, temperature, topK, maxTokens)
+	}
+	if body.Format != "json" {
+		t.Errorf("format = %q, want json", body.Format)
+	}
+
+	want := llm.Usage{PromptTokens: 10, OutputTokens: 2, TotalTokens: 12}
+	if resp.Text != "two words" || resp.Provider != "ollama" || resp.Model != "mock-model" {
+		t.Errorf("response = %q from %s/%s", resp.Text, resp.Provider, resp.Model)
+	}
+	if resp.FinishReason != llm.FinishStop {
+		t.Errorf("finish reason = %q, want STOP", resp.FinishReason)
+	}
+	if resp.Usage != want {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+}
+
+func TestOllamaResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		reply      llmtest.Reply
+		wantFinish string
+		wantStatus int
+		wantErr    error
+	}{
+		{name: "stop", reply: llmtest.Reply{Text: "ok"}, wantFinish: llm.FinishStop},
+		{name: "length", reply: llmtest.Reply{Text: "cut", FinishReason: llm.FinishMaxTokens}, wantFinish: llm.FinishMaxTokens},
+		{name: "empty", reply: llmtest.Reply{}, wantErr: llm.ErrNoContent},
+		{name: "empty at token limit", reply: llmtest.Reply{FinishReason: llm.FinishMaxTokens}, wantErr: llm.ErrNoContent},
+		{name: "model missing", reply: llmtest.Reply{Status: 404, ErrorMessage: `model "llama3.1" not found`}, wantStatus: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.NewServer(tt.reply)
+			defer srv.Close()
+			provider := llm.NewOllama(srv.URL, http.DefaultClient)
+
+			resp, err := provider.Generate(context.Background(), &llm.Request{
+				Model:    "llama3.1",
+				Contents: llm.UserText("hello"),
+			})
+
+			var apiErr *llm.APIError
+			switch {
+			case tt.wantStatus != 0:
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus || apiErr.Message != tt.reply.ErrorMessage {
+					t.Errorf("error = %v, want APIError %d %q", err, tt.wantStatus, tt.reply.ErrorMessage)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Generate: %v", err)
+				}
+				if resp.FinishReason != tt.wantFinish {
+					t.Errorf("finish reason = %q, want %q", resp.FinishReason, tt.wantFinish)
+				}
+			}
+		})
+	}
+}
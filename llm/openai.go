@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAI talks to any OpenAI-compatible /chat/completions endpoint (OpenAI,
+// Azure, vLLM, LM Studio, ...). BaseURL includes the version prefix, e.g.
+// "http://localhost:8000/v1".
+type OpenAI struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+func NewOpenAI(baseURL, apiKey string, httpClient *http.Client) *OpenAI {
+	return &OpenAI{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: httpClient,
+	}
+}
+
+func (o *OpenAI) Name() string { return "openai" }
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	MaxTokens   *int            `json:"max_tokens,omitempty"`
+	// ResponseFormat switches to JSON mode. The json_schema variant is not
+	// supported by every compatible server, so the schema itself is not
+	// sent.
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type string `json:"type"`
+}
+
+type openAIResponse struct {
+	Model string `json:"model"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+type openAIError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+func (o *OpenAI) Generate(ctx context.Context, req *Request) (*Response, error) {
+	body := openAIRequest{
+		Model:    req.Model,
+		Messages: openAIMessages(req.SystemInstruction, req.Contents),
+	}
+	// topK has no equivalent in the chat completions API and safety
+	// settings are Gemini-only, so both are dropped here.
+	if gc := req.GenerationConfig; gc != nil {
+		body.Temperature = gc.Temperature
+		body.TopP = gc.TopP
+		body.MaxTokens = gc.MaxOutputTokens
+		if gc.ResponseMimeType == MimeJSON {
+			body.ResponseFormat = &openAIResponseFormat{Type: "json_object"}
+		}
+	}
+
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request body: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to OpenAI-compatible API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, o.apiError(resp)
+	}
+
+	var oaResp openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&oaResp); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAI-compatible response: %v", err)
+	}
+
+	if len(oaResp.Choices) == 0 {
+		return nil, ErrNoContent
+	}
+	choice := oaResp.Choices[0]
+	if choice.Message.Content == "" {
+		return nil, emptyCandidateError(openAIFinishReason(choice.FinishReason), nil)
+	}
+
+	model := oaResp.Model
+	if model == "" {
+		model = req.Model
+	}
+
+	return &Response{
+		Text:         choice.Message.Content,
+		Provider:     o.Name(),
+		Model:        model,
+		FinishReason: openAIFinishReason(choice.FinishReason),
+		Usage: Usage{
+			PromptTokens: oaResp.Usage.PromptTokens,
+			OutputTokens: oaResp.Usage.CompletionTokens,
+			TotalTokens:  oaResp.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+func (o *OpenAI) apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	msg := strings.TrimSpace(string(body))
+	var oe openAIError
+	if json.Unmarshal(body, &oe) == nil && oe.Error.Message != "" {
+		msg = oe.Error.Message
+	}
+	return &APIError{
+		Provider:   "OpenAI-compatible",
+		StatusCode: resp.StatusCode,
+		Message:    msg,
+		RetryAfter: parseRetryAfter(resp.Header),
+	}
+}
+
+// openAIMessages flattens the conversation into chat messages, with the
+// system instruction, if any, as the leading system message.
+func openAIMessages(system *Content, contents []Content) []openAIMessage {
+	msgs := make([]openAIMessage, 0, len(contents)+1)
+	if system != nil {
+		msgs = append(msgs, openAIMessage{Role: "system", Content: joinText(system.Parts)})
+	}
+	for _, c := range contents {
+		role := "user"
+		if c.Role == RoleModel {
+			role = "assistant"
+		}
+		msgs = append(msgs, openAIMessage{Role: role, Content: joinText(c.Parts)})
+	}
+	return msgs
+}
+
+// openAIFinishReason maps chat completion finish reasons onto the Gemini
+// vocabulary used by Response.FinishReason.
+func openAIFinishReason(reason string) string {
+	switch reason {
+	case "stop":
+		return FinishStop
+	case "length":
+		return FinishMaxTokens
+	case "content_filter":
+		return FinishSafety
+	case "":
+		return ""
+	default:
+		return strings.ToUpper(reason)
+	}
+}
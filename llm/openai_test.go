@@ -0,0 +1,156 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"stock-analysis-api/llm"
+	"stock-analysis-api/llm/llmtest"
+)
+
+// openAIBody is the part of a chat completions request the tests check.
+type openAIBody struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+	Temperature    *float64 `json:"temperature"`
+	MaxTokens      *int     `json:"max_tokens"`
+	ResponseFormat *struct {
+		Type string `json:"type"`
+	} `json:"response_format"`
+}
+
+func TestOpenAIRequest(t *testing.T) {
+	srv := llmtest.NewServer(llmtest.Reply{Text: "two words"})
+	defer srv.Close()
+	provider := llm.NewOpenAI(srv.URL+"/v1/", "sk-test", http.DefaultClient)
+
+	temperature, maxTokens := 0.2, 512
+	resp, err := provider.Generate(context.Background(), &llm.Request{
+		Model:             "gpt-4o-mini",
+		SystemInstruction: &llm.Content{Parts: []llm.Part{{Text: "You are an analyst."}}},
+		Contents: []llm.Content{
+			{Role: llm.RoleUser, Parts: []llm.Part{{Text: "Analyze BBRI"}}},
+			{Role: llm.RoleModel, Parts: []llm.Part{{Text: "BUY"}}},
+			{Role: llm.RoleUser, Parts: []llm.Part{{Text: "Why?"}}},
+		},
+		GenerationConfig: &llm.GenerationConfig{
+			Temperature:      &temperature,
+			MaxOutputTokens:  &maxTokens,
+			ResponseMimeType: llm.MimeJSON,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	calls := srv.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("calls = %d, want 1", len(calls))
+	}
+	call := calls[0]
+	if call.Path != "/v1/chat/completions" {
+		t.Errorf("path = %q, want /v1/chat/completions", call.Path)
+	}
+	if got := call.Header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q, want Bearer sk-test", got)
+	}
+
+	var body openAIBody
+	if err := json.Unmarshal(call.Body, &body); err != nil {
+		t.Fatalf("request body: %v", err)
+	}
+	if body.Model != "gpt-4o-mini" {
+		t.Errorf("model = %q, want gpt-4o-mini", body.Model)
+	}
+	wantMessages := [][2]string{
+		{"system", "You are an analyst."},
+		{"user", "Analyze BBRI"},
+		{"assistant", "BUY"},
+		{"user", "Why?"},
+	}
+	if len(body.Messages) != len(wantMessages) {
+		t.Fatalf("messages = %+v, want %v", body.Messages, wantMessages)
+	}
+	for i, want := range wantMessages {
+		if got := body.Messages[i]; got.Role != want[0] || got.Content != want[1] {
+			t.Errorf("message %d = %s %q, want %s %q", i, got.Role, got.Content, want[0], want[1])
+		}
+	}
+	if body.Temperature == nil || *body.Temperature != temperature {
+		t.Errorf("temperature = %v, want %v", body.Temperature, temperature)
+	}
+	if body.MaxTokens == nil || *body.MaxTokens != maxTokens {
+		t.Errorf("max_tokens = %v, want %d", body.MaxTokens, maxTokens)
+	}
+	if body.ResponseFormat == nil || body.ResponseFormat.Type != "json_object" {
+		t.Errorf("response_format = %+v, want json_object", body.ResponseFormat)
+	}
+
+	want := llm.Usage{PromptTokens: 10, OutputTokens: 2, TotalTokens: 12}
+	if resp.Text != "two words" || resp.Provider != "openai" || resp.Model != "mock-model" {
+		t.Errorf("response = %q from %s/%s", resp.Text, resp.Provider, resp.Model)
+	}
+	if resp.FinishReason != llm.FinishStop {
+		t.Errorf("finish reason = %q, want STOP", resp.FinishReason)
+	}
+	if resp.Usage != want {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+}
+
+func TestOpenAIResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		reply      llmtest.Reply
+		wantFinish string
+		wantStatus int
+		wantBlock  bool
+	}{
+		{name: "no key sends no Authorization", reply: llmtest.Reply{Text: "ok"}, wantFinish: llm.FinishStop},
+		{name: "length", apiKey: "sk", reply: llmtest.Reply{Text: "cut", FinishReason: llm.FinishMaxTokens}, wantFinish: llm.FinishMaxTokens},
+		{name: "content filter", apiKey: "sk", reply: llmtest.Reply{FinishReason: llm.FinishSafety}, wantBlock: true},
+		{name: "rate limited", apiKey: "sk", reply: llmtest.Reply{Status: 429, ErrorMessage: "slow down"}, wantStatus: 429},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.NewServer(tt.reply)
+			defer srv.Close()
+			provider := llm.NewOpenAI(srv.URL+"/v1", tt.apiKey, http.DefaultClient)
+
+			resp, err := provider.Generate(context.Background(), &llm.Request{
+				Model:    "mock-model",
+				Contents: llm.UserText("hello"),
+			})
+			if auth := srv.Calls()[0].Header.Get("Authorization"); (auth != "") != (tt.apiKey != "") {
+				t.Errorf("Authorization = %q with key %q", auth, tt.apiKey)
+			}
+
+			var apiErr *llm.APIError
+			var blocked *llm.BlockedError
+			switch {
+			case tt.wantStatus != 0:
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.wantStatus || apiErr.Message != tt.reply.ErrorMessage {
+					t.Errorf("error = %v, want APIError %d %q", err, tt.wantStatus, tt.reply.ErrorMessage)
+				}
+			case tt.wantBlock:
+				if !errors.As(err, &blocked) {
+					t.Errorf("error = %v, want BlockedError", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Generate: %v", err)
+				}
+				if resp.FinishReason != tt.wantFinish {
+					t.Errorf("finish reason = %q, want %q", resp.FinishReason, tt.wantFinish)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package llm
+
+import "fmt"
+
+// defaultPersonas are available even without a config file. Each one
+// carries the persona, the trading mandate and the output rules, so the
+// prompt bodies only describe the task.
+func defaultPersonas() map[string]string {
+	return map[string]string{
+		// portfolio-manager writes the single-stock analysis.
+		"portfolio-manager": `Anda adalah senior portfolio manager dari investment firm terkemuka di Jakarta dengan akses ke Bloomberg terminal dan data real-time.
+
+IMPORTANT CONTEXT: Ini adalah analisis internal untuk klien professional dengan modal Rp 7.5 juta. Berikan analisis praktis dan actionable.
+
+TRADING PROFILE:
+- Capital: Rp 7,500,000
+- Target: 4-5% profit per trade
+- Style: Active day/swing trading
+- Risk tolerance: Medium-aggressive
+
+Provide practical, actionable analysis based on current market knowledge for Indonesian stocks. Focus on realistic price levels and executable strategy for Rp 7.5M capital.`,
+		// head-trader writes the daily picks.
+		"head-trader": `Anda adalah head trader di investment firm Jakarta dengan 15 tahun pengalaman trading saham Indonesia.
+
+TRADING MANDATE:
+- Capital: Rp 7,500,000
+- Target: 4-5% per trade
+- Style: Active day/swing trading
+- Timeline: 1-3 days per position
+
+Provide actionable recommendations with specific stock names, realistic prices, and clear entry/exit levels. Focus on liquid Indonesian stocks suitable for Rp 7.5M capital deployment.`,
+	}
+}
+
+// personaFor returns the persona name and system instruction text for a
+// request: an explicit Request.Persona, else the endpoint's persona.
+func (c Config) personaFor(req *Request) (string, string, error) {
+	name := req.Persona
+	if name == "" {
+		name = c.Endpoints[req.Endpoint].Persona
+	}
+	if name == "" {
+		return "", "", nil
+	}
+	text, ok := c.Personas[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown persona %q", name)
+	}
+	return name, text, nil
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryConfig bounds how often a transient upstream failure is retried.
+type RetryConfig struct {
+	// MaxAttempts counts the first try; 1 disables retries.
+	MaxAttempts int      `json:"max_attempts"`
+	BaseDelay   Duration `json:"base_delay"`
+	MaxDelay    Duration `json:"max_delay"`
+}
+
+// backoff returns the delay before retry number attempt (0-based) using
+// exponential growth with full jitter over the upper half of the window.
+func (rc RetryConfig) backoff(attempt int) time.Duration {
+	d := time.Duration(rc.BaseDelay) << uint(attempt)
+	if max := time.Duration(rc.MaxDelay); d <= 0 || d > max {
+		d = max
+	}
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// withRetry runs call until it succeeds, fails with a non-retryable error or
+// runs out of attempts. A Retry-After hint longer than MaxDelay is not
+// waited out; the error is returned at once so the hint reaches our client.
+func withRetry(ctx context.Context, rc RetryConfig, call func() (*Response, error)) (*Response, error) {
+	attempts := rc.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		resp, err := call()
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if !IsRetryable(err) {
+			return nil, err
+		}
+
+		delay := rc.backoff(attempt)
+		if hint := retryHint(err); hint > 0 {
+			if hint > time.Duration(rc.MaxDelay) {
+				return nil, &ExhaustedError{Attempts: attempt + 1, Err: err, RetryAfter: hint}
+			}
+			delay = hint
+		}
+		if attempt == attempts-1 {
+			return nil, &ExhaustedError{Attempts: attempts, Err: err, RetryAfter: delay}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil, lastErr
+}
+
+func retryHint(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
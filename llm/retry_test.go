@@ -0,0 +1,106 @@
+package llm_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"stock-analysis-api/llm"
+	"stock-analysis-api/llm/llmtest"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		replies     []llmtest.Reply
+		maxDelay    time.Duration
+		wantCalls   int
+		wantText    string
+		wantStatus  int
+		wantCode    string
+		wantRetry   int
+		minDuration time.Duration
+	}{
+		{
+			name:      "transient error then success",
+			replies:   []llmtest.Reply{{Status: 503, ErrorMessage: "overloaded"}, {Text: "ok"}},
+			wantCalls: 2,
+			wantText:  "ok",
+		},
+		{
+			name:       "attempts exhausted",
+			replies:    []llmtest.Reply{{Status: 503, ErrorMessage: "overloaded"}},
+			wantCalls:  3,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   llm.CodeUpstreamUnavailable,
+			wantRetry:  1,
+		},
+		{
+			name:       "client error is not retried",
+			replies:    []llmtest.Reply{{Status: 400, ErrorMessage: "bad request"}},
+			wantCalls:  1,
+			wantStatus: http.StatusBadGateway,
+			wantCode:   llm.CodeUpstreamError,
+		},
+		{
+			name:       "Retry-After longer than MaxDelay is passed on",
+			replies:    []llmtest.Reply{{Status: 429, ErrorMessage: "quota", RetryAfter: 30 * time.Second}},
+			wantCalls:  1,
+			wantStatus: http.StatusTooManyRequests,
+			wantCode:   llm.CodeRateLimited,
+			wantRetry:  30,
+		},
+		{
+			name:        "Retry-After within MaxDelay is waited out",
+			replies:     []llmtest.Reply{{Status: 429, ErrorMessage: "quota", RetryAfter: time.Second}, {Text: "ok"}},
+			maxDelay:    2 * time.Second,
+			wantCalls:   2,
+			wantText:    "ok",
+			minDuration: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := llmtest.NewServer(tt.replies...)
+			defer srv.Close()
+			client := newClient(t, srv, func(cfg *llm.Config) {
+				if tt.maxDelay > 0 {
+					cfg.Retry.MaxDelay = llm.Duration(tt.maxDelay)
+				}
+			})
+
+			start := time.Now()
+			resp, err := generate(client)
+			elapsed := time.Since(start)
+
+			if got := len(srv.Calls()); got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+			if elapsed < tt.minDuration {
+				t.Errorf("returned after %v, want at least %v", elapsed, tt.minDuration)
+			}
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("Generate: %v", err)
+				}
+				if resp.Text != tt.wantText {
+					t.Errorf("text = %q, want %q", resp.Text, tt.wantText)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Generate succeeded, want an error")
+			}
+			if got := llm.HTTPStatus(err); got != tt.wantStatus {
+				t.Errorf("HTTPStatus = %d, want %d", got, tt.wantStatus)
+			}
+			if got := llm.ErrorCode(err); got != tt.wantCode {
+				t.Errorf("ErrorCode = %q, want %q", got, tt.wantCode)
+			}
+			if got := llm.RetryAfterSeconds(err); got != tt.wantRetry {
+				t.Errorf("RetryAfterSeconds = %d, want %d", got, tt.wantRetry)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package llm
+
+import "fmt"
+
+// Harm categories and thresholds understood by Gemini.
+const (
+	HarmDangerousContent = "HARM_CATEGORY_DANGEROUS_CONTENT"
+	HarmHarassment       = "HARM_CATEGORY_HARASSMENT"
+	HarmHateSpeech       = "HARM_CATEGORY_HATE_SPEECH"
+	HarmSexuallyExplicit = "HARM_CATEGORY_SEXUALLY_EXPLICIT"
+
+	BlockNone           = "BLOCK_NONE"
+	BlockOnlyHigh       = "BLOCK_ONLY_HIGH"
+	BlockMediumAndAbove = "BLOCK_MEDIUM_AND_ABOVE"
+	BlockLowAndAbove    = "BLOCK_LOW_AND_ABOVE"
+)
+
+// defaultSafetyProfiles are available even without a config file. Only
+// Gemini enforces them; other providers ignore safety settings.
+func defaultSafetyProfiles() map[string][]SafetySetting {
+	return map[string][]SafetySetting{
+		// strict is meant for free-form user input on /api/prompt.
+		"strict": {
+			{Category: HarmDangerousContent, Threshold: BlockLowAndAbove},
+			{Category: HarmHarassment, Threshold: BlockLowAndAbove},
+			{Category: HarmHateSpeech, Threshold: BlockLowAndAbove},
+			{Category: HarmSexuallyExplicit, Threshold: BlockLowAndAbove},
+		},
+		// market-analysis relaxes only the two categories that trading
+		// language ("aggressive", "kill the position") tends to trip.
+		"market-analysis": {
+			{Category: HarmDangerousContent, Threshold: BlockNone},
+			{Category: HarmHarassment, Threshold: BlockNone},
+			{Category: HarmHateSpeech, Threshold: BlockMediumAndAbove},
+			{Category: HarmSexuallyExplicit, Threshold: BlockMediumAndAbove},
+		},
+		// provider-default sends no settings and leaves filtering to the
+		// upstream defaults.
+		"provider-default": {},
+	}
+}
+
+// safetyProfileFor returns the profile name and settings for a request: an
+// explicit Request.SafetyProfile, else the endpoint's profile.
+func (c Config) safetyProfileFor(req *Request) (string, []SafetySetting, error) {
+	name := req.SafetyProfile
+	if name == "" {
+		name = c.Endpoints[req.Endpoint].SafetyProfile
+	}
+	if name == "" {
+		return "", nil, nil
+	}
+	settings, ok := c.SafetyProfiles[name]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown safety profile %q", name)
+	}
+	return name, settings, nil
+}
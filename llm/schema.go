@@ -0,0 +1,23 @@
+package llm
+
+// Schema types in the OpenAPI subset Gemini accepts.
+const (
+	TypeObject  = "OBJECT"
+	TypeArray   = "ARRAY"
+	TypeString  = "STRING"
+	TypeNumber  = "NUMBER"
+	TypeInteger = "INTEGER"
+	TypeBoolean = "BOOLEAN"
+)
+
+// Schema describes JSON data for function parameters and structured
+// responses.
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+}
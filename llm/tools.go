@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Tool groups the function declarations offered to the model.
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations"`
+}
+
+type FunctionDeclaration struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Parameters  *Schema `json:"parameters,omitempty"`
+}
+
+// FunctionCall is a tool invocation requested by the model.
+type FunctionCall struct {
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// FunctionResponse carries a tool result back to the model.
+type FunctionResponse struct {
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// ToolCall records one executed tool call for auditing.
+type ToolCall struct {
+	Name  string                 `json:"name"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+	Error string                 `json:"error,omitempty"`
+}
+
+// ToolFunc executes a tool. The result is sent to the model as JSON; an
+// error is sent as {"error": message} so the model can work around it.
+type ToolFunc func(ctx context.Context, args map[string]interface{}) (interface{}, error)
+
+// ToolSet is the set of server-side tools available to one request.
+type ToolSet struct {
+	decls []FunctionDeclaration
+	funcs map[string]ToolFunc
+}
+
+func NewToolSet() *ToolSet {
+	return &ToolSet{funcs: make(map[string]ToolFunc)}
+}
+
+// Register adds a tool. Registering the same name twice replaces it.
+func (t *ToolSet) Register(decl FunctionDeclaration, fn ToolFunc) {
+	if _, exists := t.funcs[decl.Name]; !exists {
+		t.decls = append(t.decls, decl)
+	}
+	t.funcs[decl.Name] = fn
+}
+
+func (t *ToolSet) call(ctx context.Context, fc FunctionCall) (map[string]interface{}, error) {
+	fn, ok := t.funcs[fc.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tool %q", fc.Name)
+	}
+	result, err := fn(ctx, fc.Args)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"result": result}, nil
+}
+
+// maxToolRounds bounds the call/execute loop so a model that keeps asking
+// for tools cannot run forever.
+const maxToolRounds = 6
+
+// GenerateWithTools offers tools to the model and executes the calls it
+// makes, feeding results back until it returns a final answer. Only Gemini
+// supports tools; other providers answer directly.
+func (c *Client) GenerateWithTools(ctx context.Context, req *Request, tools *ToolSet) (*Response, error) {
+	return c.cached(ctx, req, tools, func() (*Response, error) {
+		return c.runTools(ctx, req, tools, c.generate)
+	})
+}
+
+// StreamWithTools is GenerateWithTools with the final answer streamed
+// through onChunk.
+func (c *Client) StreamWithTools(ctx context.Context, req *Request, tools *ToolSet, onChunk ChunkFunc) (*Response, error) {
+	return c.runTools(ctx, req, tools, func(ctx context.Context, r *Request) (*Response, error) {
+		return c.Stream(ctx, r, onChunk)
+	})
+}
+
+func (c *Client) runTools(ctx context.Context, req *Request, tools *ToolSet, call func(context.Context, *Request) (*Response, error)) (*Response, error) {
+	cur := *req
+	cur.Tools = append(append([]Tool(nil), req.Tools...), Tool{FunctionDeclarations: tools.decls})
+
+	var usage Usage
+	var calls []ToolCall
+	for round := 0; round < maxToolRounds; round++ {
+		resp, err := call(ctx, &cur)
+		if err != nil {
+			return nil, err
+		}
+		usage.Add(resp.Usage)
+
+		if len(resp.FunctionCalls) == 0 {
+			resp.Usage = usage
+			resp.ToolCalls = calls
+			return resp, nil
+		}
+
+		modelTurn := Content{Role: RoleModel}
+		resultTurn := Content{Role: RoleUser}
+		for i := range resp.FunctionCalls {
+			fc := resp.FunctionCalls[i]
+			modelTurn.Parts = append(modelTurn.Parts, Part{FunctionCall: &fc})
+
+			record := ToolCall{Name: fc.Name, Args: fc.Args}
+			result, err := tools.call(ctx, fc)
+			if err != nil {
+				record.Error = err.Error()
+				result = map[string]interface{}{"error": err.Error()}
+			}
+			calls = append(calls, record)
+			resultTurn.Parts = append(resultTurn.Parts, Part{
+				FunctionResponse: &FunctionResponse{Name: fc.Name, Response: result},
+			})
+		}
+		cur.Contents = append(append([]Content(nil), cur.Contents...), modelTurn, resultTurn)
+	}
+	return nil, fmt.Errorf("model did not produce an answer after %d tool rounds", maxToolRounds)
+}
+
+// ArgString reads a string argument from a function call.
+func ArgString(args map[string]interface{}, name string) (string, error) {
+	v, ok := args[name].(string)
+	if !ok || v == "" {
+		return "", fmt.Errorf("argument %q is required", name)
+	}
+	return v, nil
+}
+
+// ArgInt reads an optional integer argument, returning def when absent.
+// JSON numbers arrive as float64.
+func ArgInt(args map[string]interface{}, name string, def int) (int, error) {
+	raw, ok := args[name]
+	if !ok || raw == nil {
+		return def, nil
+	}
+	switch v := raw.(type) {
+	case float64:
+		return int(v), nil
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err
+	}
+	return 0, fmt.Errorf("argument %q must be a number", name)
+}
@@ -0,0 +1,231 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"stock-analysis-api/cache"
+	"stock-analysis-api/idx"
+)
+
+// Usage is the token count of one or more calls and its estimated cost.
+type Usage struct {
+	PromptTokens int     `json:"prompt_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalTokens  int     `json:"total_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// Add accumulates o into u.
+func (u *Usage) Add(o Usage) {
+	u.PromptTokens += o.PromptTokens
+	u.OutputTokens += o.OutputTokens
+	u.TotalTokens += o.TotalTokens
+	u.CostUSD += o.CostUSD
+}
+
+// ModelPrice is the list price of a model in USD per million tokens.
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+func defaultPricing() map[string]ModelPrice {
+	return map[string]ModelPrice{
+		"gemini-2.0-flash": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+		"gemini-1.5-flash": {InputPerMillion: 0.075, OutputPerMillion: 0.30},
+	}
+}
+
+// estimateCost prices u with the table entry for model. Unknown models,
+// such as local Ollama ones, cost nothing.
+func estimateCost(pricing map[string]ModelPrice, model string, u Usage) float64 {
+	price, ok := pricing[model]
+	if !ok {
+		return 0
+	}
+	return float64(u.PromptTokens)/1e6*price.InputPerMillion +
+		float64(u.OutputTokens)/1e6*price.OutputPerMillion
+}
+
+// UsageRow is the aggregated usage of one endpoint and model on one day.
+type UsageRow struct {
+	Day      string `json:"day"`
+	Endpoint string `json:"endpoint"`
+	Model    string `json:"model"`
+	Requests int    `json:"requests"`
+	Usage
+}
+
+// UsageStore aggregates usage per day, endpoint and model.
+type UsageStore interface {
+	// Record books one call at time at.
+	Record(ctx context.Context, at time.Time, endpoint, model string, u Usage) error
+	// Rows returns the aggregates, optionally limited to one day, sorted
+	// by day, endpoint and model.
+	Rows(ctx context.Context, day string) ([]UsageRow, error)
+}
+
+func usageDay(at time.Time) string {
+	return at.In(idx.WIB).Format("2006-01-02")
+}
+
+func sortUsage(rows []UsageRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Day != rows[j].Day {
+			return rows[i].Day < rows[j].Day
+		}
+		if rows[i].Endpoint != rows[j].Endpoint {
+			return rows[i].Endpoint < rows[j].Endpoint
+		}
+		return rows[i].Model < rows[j].Model
+	})
+}
+
+type usageKey struct {
+	day, endpoint, model string
+}
+
+// UsageTracker aggregates usage in memory for the lifetime of the process.
+type UsageTracker struct {
+	mu   sync.Mutex
+	rows map[usageKey]*UsageRow
+}
+
+func NewUsageTracker() *UsageTracker {
+	return &UsageTracker{rows: make(map[usageKey]*UsageRow)}
+}
+
+func (t *UsageTracker) Record(_ context.Context, at time.Time, endpoint, model string, u Usage) error {
+	if endpoint == "" {
+		endpoint = "unknown"
+	}
+	key := usageKey{day: usageDay(at), endpoint: endpoint, model: model}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	row, ok := t.rows[key]
+	if !ok {
+		row = &UsageRow{Day: key.day, Endpoint: endpoint, Model: model}
+		t.rows[key] = row
+	}
+	row.Requests++
+	row.Add(u)
+	return nil
+}
+
+func (t *UsageTracker) Rows(_ context.Context, day string) ([]UsageRow, error) {
+	t.mu.Lock()
+	rows := make([]UsageRow, 0, len(t.rows))
+	for k, r := range t.rows {
+		if day == "" || k.day == day {
+			rows = append(rows, *r)
+		}
+	}
+	t.mu.Unlock()
+
+	sortUsage(rows)
+	return rows, nil
+}
+
+// RedisUsage aggregates usage in Redis. Each day is a hash under
+// "usage:<day>" with one field per endpoint, model and counter;
+// "usage:days" lists the days.
+type RedisUsage struct {
+	redis *cache.Redis
+}
+
+func NewRedisUsage(r *cache.Redis) *RedisUsage {
+	return &RedisUsage{redis: r}
+}
+
+const (
+	usageDaysKey = "usage:days"
+	// usageSep separates endpoint, model and counter in a hash field.
+	usageSep = "|"
+)
+
+// recordUsage increments the counters of one call atomically.
+const recordUsage = `
+local f = ARGV[1] .. '|'
+redis.call('HINCRBY', KEYS[1], f .. 'requests', 1)
+redis.call('HINCRBY', KEYS[1], f .. 'prompt_tokens', ARGV[2])
+redis.call('HINCRBY', KEYS[1], f .. 'output_tokens', ARGV[3])
+redis.call('HINCRBY', KEYS[1], f .. 'total_tokens', ARGV[4])
+redis.call('HINCRBYFLOAT', KEYS[1], f .. 'cost_usd', ARGV[5])
+redis.call('SADD', KEYS[2], ARGV[6])
+return 1`
+
+func (s *RedisUsage) Record(ctx context.Context, at time.Time, endpoint, model string, u Usage) error {
+	if endpoint == "" {
+		endpoint = "unknown"
+	}
+	day := usageDay(at)
+	_, err := s.redis.Do(ctx, "EVAL", recordUsage, "2", "usage:"+day, usageDaysKey,
+		endpoint+usageSep+model,
+		strconv.Itoa(u.PromptTokens),
+		strconv.Itoa(u.OutputTokens),
+		strconv.Itoa(u.TotalTokens),
+		strconv.FormatFloat(u.CostUSD, 'f', -1, 64),
+		day)
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisUsage) Rows(ctx context.Context, day string) ([]UsageRow, error) {
+	days := []string{day}
+	if day == "" {
+		var err error
+		if days, err = s.redis.Strings(ctx, "SMEMBERS", usageDaysKey); err != nil {
+			return nil, fmt.Errorf("failed to read usage: %v", err)
+		}
+	}
+
+	rows := []UsageRow{}
+	for _, d := range days {
+		fields, err := s.redis.Strings(ctx, "HGETALL", "usage:"+d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read usage: %v", err)
+		}
+		byKey := map[usageKey]*UsageRow{}
+		for i := 0; i+1 < len(fields); i += 2 {
+			parts := strings.Split(fields[i], usageSep)
+			if len(parts) != 3 {
+				continue
+			}
+			key := usageKey{day: d, endpoint: parts[0], model: parts[1]}
+			row, ok := byKey[key]
+			if !ok {
+				row = &UsageRow{Day: d, Endpoint: key.endpoint, Model: key.model}
+				byKey[key] = row
+			}
+			v := fields[i+1]
+			switch parts[2] {
+			case "requests":
+				row.Requests, _ = strconv.Atoi(v)
+			case "prompt_tokens":
+				row.PromptTokens, _ = strconv.Atoi(v)
+			case "output_tokens":
+				row.OutputTokens, _ = strconv.Atoi(v)
+			case "total_tokens":
+				row.TotalTokens, _ = strconv.Atoi(v)
+			case "cost_usd":
+				row.CostUSD, _ = strconv.ParseFloat(v, 64)
+			}
+		}
+		for _, row := range byKey {
+			rows = append(rows, *row)
+		}
+	}
+
+	sortUsage(rows)
+	return rows, nil
+}
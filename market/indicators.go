@@ -0,0 +1,140 @@
+package market
+
+import (
+	"fmt"
+	"math"
+)
+
+// Indicators are computed from daily closes, not taken from the model.
+// Fields are nil when there is not enough history to compute them.
+type Indicators struct {
+	Code          string   `json:"code"`
+	Candles       int      `json:"candles"`
+	LastClose     float64  `json:"last_close"`
+	RSI14         *float64 `json:"rsi_14,omitempty"`
+	MACD          *float64 `json:"macd,omitempty"`
+	MACDSignal    *float64 `json:"macd_signal,omitempty"`
+	MACDHistogram *float64 `json:"macd_histogram,omitempty"`
+	SMA20         *float64 `json:"sma_20,omitempty"`
+	SMA50         *float64 `json:"sma_50,omitempty"`
+	AvgVolume20   *float64 `json:"avg_volume_20,omitempty"`
+}
+
+// ComputeIndicators derives the standard indicators from candles, which
+// must be ordered oldest first.
+func ComputeIndicators(code string, candles []Candle) (*Indicators, error) {
+	if len(candles) == 0 {
+		return nil, fmt.Errorf("no price history for %s", code)
+	}
+
+	closes := make([]float64, len(candles))
+	volumes := make([]float64, len(candles))
+	for i, c := range candles {
+		closes[i] = c.Close
+		volumes[i] = float64(c.Volume)
+	}
+
+	ind := &Indicators{
+		Code:        code,
+		Candles:     len(candles),
+		LastClose:   closes[len(closes)-1],
+		RSI14:       rsi(closes, 14),
+		SMA20:       sma(closes, 20),
+		SMA50:       sma(closes, 50),
+		AvgVolume20: sma(volumes, 20),
+	}
+	ind.MACD, ind.MACDSignal, ind.MACDHistogram = macd(closes, 12, 26, 9)
+	return ind, nil
+}
+
+func sma(values []float64, period int) *float64 {
+	if len(values) < period {
+		return nil
+	}
+	sum := 0.0
+	for _, v := range values[len(values)-period:] {
+		sum += v
+	}
+	return round2(sum / float64(period))
+}
+
+// rsi uses Wilder's smoothing.
+func rsi(closes []float64, period int) *float64 {
+	if len(closes) <= period {
+		return nil
+	}
+
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		g, l := gainLoss(closes[i] - closes[i-1])
+		gain += g
+		loss += l
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+
+	for i := period + 1; i < len(closes); i++ {
+		g, l := gainLoss(closes[i] - closes[i-1])
+		gain = (gain*float64(period-1) + g) / float64(period)
+		loss = (loss*float64(period-1) + l) / float64(period)
+	}
+
+	if loss == 0 {
+		return round2(100)
+	}
+	return round2(100 - 100/(1+gain/loss))
+}
+
+func gainLoss(change float64) (gain, loss float64) {
+	if change > 0 {
+		return change, 0
+	}
+	return 0, -change
+}
+
+// ema returns the exponential moving average series seeded with the SMA of
+// the first period values. The result is aligned with values[period-1:].
+func ema(values []float64, period int) []float64 {
+	if len(values) < period {
+		return nil
+	}
+	k := 2 / float64(period+1)
+
+	seed := 0.0
+	for _, v := range values[:period] {
+		seed += v
+	}
+	out := []float64{seed / float64(period)}
+	for _, v := range values[period:] {
+		out = append(out, v*k+out[len(out)-1]*(1-k))
+	}
+	return out
+}
+
+func macd(closes []float64, fast, slow, signal int) (*float64, *float64, *float64) {
+	slowEMA := ema(closes, slow)
+	if slowEMA == nil {
+		return nil, nil, nil
+	}
+	fastEMA := ema(closes, fast)
+
+	// Align the fast series with the slow one, which starts later.
+	offset := slow - fast
+	line := make([]float64, len(slowEMA))
+	for i := range slowEMA {
+		line[i] = fastEMA[i+offset] - slowEMA[i]
+	}
+
+	m := line[len(line)-1]
+	signalEMA := ema(line, signal)
+	if signalEMA == nil {
+		return round2(m), nil, nil
+	}
+	sig := signalEMA[len(signalEMA)-1]
+	return round2(m), round2(sig), round2(m - sig)
+}
+
+func round2(v float64) *float64 {
+	r := math.Round(v*100) / 100
+	return &r
+}
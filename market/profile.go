@@ -0,0 +1,72 @@
+// Package market provides the stock data the analysis endpoints ground
+// their prompts in: company profiles, live quotes and indicator values.
+package market
+
+import "fmt"
+
+// Profile is the static description of a listed company.
+type Profile struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Context string `json:"context"`
+}
+
+// CompanyProfile returns the known profile for stockCode.
+func CompanyProfile(stockCode string) Profile {
+	return Profile{
+		Code:    stockCode,
+		Name:    CompanyName(stockCode),
+		Context: StockContext(stockCode),
+	}
+}
+
+func CompanyName(stockCode string) string {
+	companies := map[string]string{
+		"CDIA": "PT Chandra Daya Investasi Tbk",
+		"GOTO": "PT GoTo Gojek Tokopedia Tbk",
+		"BBCA": "PT Bank Central Asia Tbk",
+		"BBRI": "PT Bank Rakyat Indonesia Tbk",
+		"BMRI": "PT Bank Mandiri Tbk",
+		"ASII": "PT Astra International Tbk",
+		"UNVR": "PT Unilever Indonesia Tbk",
+		"TLKM": "PT Telkom Indonesia Tbk",
+		"COIN": "PT Digital Coin Indonesia Tbk",
+		"CUAN": "PT Arha Capital Tbk",
+	}
+
+	if name, exists := companies[stockCode]; exists {
+		return name
+	}
+	return fmt.Sprintf("PT %s Tbk", stockCode)
+}
+
+func StockContext(stockCode string) string {
+	switch stockCode {
+	case "CDIA":
+		return `CURRENT MARKET DATA (Chandra Daya Investasi):
+Recent IPO with strong post-listing performance. Infrastructure/energy sector play, subsidiary of TPIA (Chandra Asri). Price range: 1,400-1,700 area based on recent trading. High volatility post-IPO typical. Multiple auto rejection atas (ARA) events. Strong fundamental backing from parent company. High retail interest. Trading volume varies significantly.`
+
+	case "GOTO":
+		return `CURRENT MARKET DATA (GoTo Gojek Tokopedia):
+Established tech stock, large cap with high liquidity. Super app ecosystem business model. Typical trading range 100-150 based on historical patterns. Medium volatility suitable for swing trading. High daily volume, easy entry/exit. Focus on path to profitability, strong user metrics.`
+
+	case "BBCA":
+		return `CURRENT MARKET DATA (Bank Central Asia):
+Premium Indonesian bank, highest quality banking stock. Typical range 8,000-10,000 based on historical. Low-medium volatility. Excellent liquidity. Consistent dividend payer. Strong digital banking. Defensive play with quality fundamentals.`
+
+	case "BBRI":
+		return `CURRENT MARKET DATA (Bank Rakyat Indonesia):
+Large government-related bank, strong SME/rural network. Typical range 4,000-5,500. Low-medium volatility. High liquidity. Government backing provides stability. Solid dividend history.`
+
+	case "CUAN":
+		return `CURRENT MARKET DATA (Arha Capital):
+Digital asset/crypto-related investment company. High volatility correlated with crypto markets. Speculative stock with high beta. Suitable for aggressive momentum traders.`
+
+	case "COIN":
+		return `CURRENT MARKET DATA (Digital Coin):
+Crypto-related business model. Extreme volatility following crypto market sentiment. High risk, high reward potential. Momentum-driven trading.`
+
+	default:
+		return `MARKET DATA: Analyze based on sector characteristics and provide realistic price estimates for Indonesian market conditions.`
+	}
+}
@@ -0,0 +1,129 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoSource is returned when no market data feed is configured.
+var ErrNoSource = errors.New("no live market data source configured (MARKET_DATA_URL is not set)")
+
+// Quote is the latest trade snapshot of a stock, in rupiah.
+type Quote struct {
+	Code      string    `json:"code"`
+	Price     float64   `json:"price"`
+	PrevClose float64   `json:"prev_close"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Volume    int64     `json:"volume"`
+	Time      time.Time `json:"time"`
+	// Board is the IDX listing board, e.g. "main" or "acceleration". It is
+	// empty when the feed does not report it.
+	Board string `json:"board,omitempty"`
+}
+
+// Candle is one daily OHLCV bar.
+type Candle struct {
+	Date   string  `json:"date"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume int64   `json:"volume"`
+}
+
+// Source supplies live market data.
+type Source interface {
+	Quote(ctx context.Context, code string) (*Quote, error)
+	// History returns up to days daily candles, oldest first.
+	History(ctx context.Context, code string, days int) ([]Candle, error)
+}
+
+// HTTPSource reads a simple JSON feed:
+//
+//	GET {base}/quote/{code}            -> Quote
+//	GET {base}/history/{code}?days=N   -> []Candle
+type HTTPSource struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewHTTPSource(baseURL string) *HTTPSource {
+	return &HTTPSource{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *HTTPSource) Quote(ctx context.Context, code string) (*Quote, error) {
+	var q Quote
+	if err := s.get(ctx, "/quote/"+url.PathEscape(code), &q); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (s *HTTPSource) History(ctx context.Context, code string, days int) ([]Candle, error) {
+	var candles []Candle
+	path := fmt.Sprintf("/history/%s?days=%d", url.PathEscape(code), days)
+	if err := s.get(ctx, path, &candles); err != nil {
+		return nil, err
+	}
+	return candles, nil
+}
+
+func (s *HTTPSource) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach market data source: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("market data source returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse market data: %v", err)
+	}
+	return nil
+}
+
+// noSource is used when MARKET_DATA_URL is unset; every call reports
+// ErrNoSource so the model knows it has to estimate.
+type noSource struct{}
+
+func (noSource) Quote(context.Context, string) (*Quote, error) { return nil, ErrNoSource }
+
+func (noSource) History(context.Context, string, int) ([]Candle, error) { return nil, ErrNoSource }
+
+var (
+	defaultOnce   sync.Once
+	defaultSource Source
+)
+
+// DefaultSource returns the feed configured by MARKET_DATA_URL.
+func DefaultSource() Source {
+	defaultOnce.Do(func() {
+		if base := os.Getenv("MARKET_DATA_URL"); base != "" {
+			defaultSource = NewHTTPSource(base)
+		} else {
+			defaultSource = noSource{}
+		}
+	})
+	return defaultSource
+}
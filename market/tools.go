@@ -0,0 +1,75 @@
+package market
+
+import (
+	"context"
+	"strings"
+
+	"stock-analysis-api/llm"
+)
+
+// historyDays is enough daily bars for SMA50 and a settled MACD signal.
+const historyDays = 120
+
+// Tools exposes src and the company profiles as LLM tools, so the model
+// looks up prices and indicators instead of estimating them.
+func Tools(src Source) *llm.ToolSet {
+	codeParam := &llm.Schema{
+		Type: llm.TypeObject,
+		Properties: map[string]*llm.Schema{
+			"stock_code": {Type: llm.TypeString, Description: "IDX ticker, e.g. BBCA"},
+		},
+		Required: []string{"stock_code"},
+	}
+
+	tools := llm.NewToolSet()
+
+	tools.Register(llm.FunctionDeclaration{
+		Name:        "get_latest_quote",
+		Description: "Latest price, previous close, day range and volume of an IDX stock, in rupiah.",
+		Parameters:  codeParam,
+	}, func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+		code, err := stockCodeArg(args)
+		if err != nil {
+			return nil, err
+		}
+		return src.Quote(ctx, code)
+	})
+
+	tools.Register(llm.FunctionDeclaration{
+		Name:        "get_company_profile",
+		Description: "Company name and background notes for an IDX stock.",
+		Parameters:  codeParam,
+	}, func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+		code, err := stockCodeArg(args)
+		if err != nil {
+			return nil, err
+		}
+		return CompanyProfile(code), nil
+	})
+
+	tools.Register(llm.FunctionDeclaration{
+		Name:        "get_indicator_values",
+		Description: "RSI(14), MACD(12,26,9), SMA20/SMA50 and 20-day average volume computed from daily closes.",
+		Parameters:  codeParam,
+	}, func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+		code, err := stockCodeArg(args)
+		if err != nil {
+			return nil, err
+		}
+		candles, err := src.History(ctx, code, historyDays)
+		if err != nil {
+			return nil, err
+		}
+		return ComputeIndicators(code, candles)
+	})
+
+	return tools
+}
+
+func stockCodeArg(args map[string]interface{}) (string, error) {
+	code, err := llm.ArgString(args, "stock_code")
+	if err != nil {
+		return "", err
+	}
+	return strings.ToUpper(strings.TrimSpace(code)), nil
+}
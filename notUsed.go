@@ -1,39 +1,19 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-)
-
-// --- STRUCTS UNTUK GEMINI API ---
-
-type GeminiRequest struct {
-	Contents []Content `json:"contents"`
-}
-
-type Content struct {
-	Parts []Part `json:"parts"`
-}
-
-type Part struct {
-	Text string `json:"text"`
-}
 
-type GeminiResponse struct {
-	Candidates []Candidate `json:"candidates"`
-}
+	"stock-analysis-api/llm"
+)
 
-type Candidate struct {
-	Content Content `json:"content"`
-}
+// --- STRUCTS UNTUK PROMPT ---
 
 type UserPromptRequest struct {
 	Prompt string `json:"prompt"`
@@ -120,7 +100,7 @@ func handleGeminiPrompt(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Prompt cannot be empty"})
 	}
 
-	response, err := callGeminiAPI(userReq.Prompt)
+	response, err := callGeminiAPI(c.UserContext(), "prompt", userReq.Prompt)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -170,7 +150,7 @@ KRITERIA SAHAM:
 
 Berikan analisis yang detail, praktis, dan actionable. Fokus pada saham-saham yang realistis bisa memberikan return 4-5%% dalam 1-2 hari trading.`, currentDate)
 
-	response, err := callGeminiAPI(prompt)
+	response, err := callGeminiAPI(c.UserContext(), "daily", prompt)
 	if err != nil {
 		return c.Status(500).JSON(StockRecommendationResponse{
 			Status: "error",
@@ -249,7 +229,7 @@ FORMAT ANALISIS:
 
 Berikan analisis yang honest, detail, dan praktis. Jika saham tidak bagus untuk trading, katakan dengan jelas dan berikan alasannya.`, req.StockCode, req.StockCode, currentDate)
 
-	response, err := callGeminiAPI(prompt)
+	response, err := callGeminiAPI(c.UserContext(), "analyze", prompt)
 	if err != nil {
 		return c.Status(500).JSON(StockRecommendationResponse{
 			Status: "error",
@@ -264,56 +244,19 @@ Berikan analisis yang honest, detail, dan praktis. Jika saham tidak bagus untuk
 	})
 }
 
-// Helper function untuk memanggil Gemini API
-func callGeminiAPI(prompt string) (string, error) {
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		return "", fmt.Errorf("GEMINI_API_KEY is not set")
-	}
-
-	geminiReqBody := GeminiRequest{
-		Contents: []Content{
-			{
-				Parts: []Part{
-					{Text: prompt},
-				},
-			},
-		},
-	}
-
-	jsonBody, err := json.Marshal(geminiReqBody)
+// Helper function untuk memanggil Gemini API lewat shared llm client
+func callGeminiAPI(ctx context.Context, endpoint, prompt string) (string, error) {
+	client, err := llm.Default()
 	if err != nil {
-		return "", fmt.Errorf("failed to create request body: %v", err)
+		return "", err
 	}
 
-	url := "https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-flash:generateContent?key=" + apiKey
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create HTTP request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := client.Generate(ctx, &llm.Request{
+		Endpoint: endpoint,
+		Contents: llm.UserText(prompt),
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to send request to Gemini API: %v", err)
+		return "", err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		var errorResponse map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&errorResponse)
-		return "", fmt.Errorf("error from Gemini API (status %d): %v", resp.StatusCode, errorResponse)
-	}
-
-	var geminiResp GeminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-		return "", fmt.Errorf("failed to parse Gemini response: %v", err)
-	}
-
-	if len(geminiResp.Candidates) > 0 && len(geminiResp.Candidates[0].Content.Parts) > 0 {
-		return geminiResp.Candidates[0].Content.Parts[0].Text, nil
-	}
-
-	return "", fmt.Errorf("no content received from Gemini")
+	return resp.Text, nil
 }
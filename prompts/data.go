@@ -0,0 +1,46 @@
+package prompts
+
+import "fmt"
+
+// AnalyzeData is the input of the "analyze" template.
+type AnalyzeData struct {
+	StockCode    string `json:"stock_code"`
+	Date         string `json:"date"`
+	CompanyName  string `json:"company_name"`
+	StockContext string `json:"stock_context"`
+}
+
+// DailyData is the input of the "daily" template.
+type DailyData struct {
+	Date string `json:"date"`
+}
+
+// NewData returns an empty data value of the type the named template
+// expects, for decoding preview input.
+func NewData(name string) (interface{}, error) {
+	switch name {
+	case "analyze":
+		return &AnalyzeData{}, nil
+	case "daily":
+		return &DailyData{}, nil
+	}
+	return nil, fmt.Errorf("no data type for prompt template %q", name)
+}
+
+// Analyze renders the "analyze" template version for clientID.
+func Analyze(clientID string, data AnalyzeData) (Rendered, error) {
+	r, err := Default()
+	if err != nil {
+		return Rendered{}, err
+	}
+	return r.RenderFor("analyze", clientID, data)
+}
+
+// Daily renders the "daily" template version for clientID.
+func Daily(clientID string, data DailyData) (Rendered, error) {
+	r, err := Default()
+	if err != nil {
+		return Rendered{}, err
+	}
+	return r.RenderFor("daily", clientID, data)
+}
@@ -0,0 +1,133 @@
+package prompts
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Experiment splits traffic for one template between versions.
+type Experiment struct {
+	Name     string    `json:"name"`
+	Template string    `json:"template"`
+	Variants []Variant `json:"variants"`
+}
+
+// Variant is a template version and its share of traffic relative to the
+// other variants' weights.
+type Variant struct {
+	Version string `json:"version"`
+	Weight  int    `json:"weight"`
+}
+
+// LoadExperiments reads a JSON array of experiments from file.
+func LoadExperiments(file string) ([]Experiment, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt experiments: %v", err)
+	}
+	var experiments []Experiment
+	if err := json.Unmarshal(data, &experiments); err != nil {
+		return nil, fmt.Errorf("failed to parse prompt experiments %s: %v", file, err)
+	}
+	return experiments, nil
+}
+
+// SetExperiments validates and installs experiments. A template can be in
+// at most one experiment at a time.
+func (r *Registry) SetExperiments(experiments []Experiment) error {
+	byTemplate := map[string]*Experiment{}
+	for i := range experiments {
+		e := &experiments[i]
+		if e.Name == "" {
+			return fmt.Errorf("prompt experiment for %q has no name", e.Template)
+		}
+		if _, dup := byTemplate[e.Template]; dup {
+			return fmt.Errorf("prompt template %q is in more than one experiment", e.Template)
+		}
+		if len(e.Variants) == 0 {
+			return fmt.Errorf("prompt experiment %q has no variants", e.Name)
+		}
+		for _, v := range e.Variants {
+			if _, err := r.Get(e.Template, v.Version); err != nil {
+				return fmt.Errorf("prompt experiment %q: %v", e.Name, err)
+			}
+			if v.Weight <= 0 {
+				return fmt.Errorf("prompt experiment %q: weight of %s must be positive", e.Name, v.Version)
+			}
+		}
+		byTemplate[e.Template] = e
+	}
+	r.experiments = byTemplate
+	return nil
+}
+
+// Experiments returns the installed experiments.
+func (r *Registry) Experiments() []Experiment {
+	out := []Experiment{}
+	for _, e := range r.experiments {
+		out = append(out, *e)
+	}
+	return out
+}
+
+// assign picks the variant of e for clientID. The same client always gets
+// the same variant as long as the variants and weights stay the same; an
+// anonymous client gets a random one.
+func (e *Experiment) assign(clientID string) string {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+
+	var n int
+	if clientID == "" {
+		n = rand.Intn(total)
+	} else {
+		sum := sha256.Sum256([]byte(e.Name + "\x00" + clientID))
+		n = int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	}
+	for _, v := range e.Variants {
+		if n < v.Weight {
+			return v.Version
+		}
+		n -= v.Weight
+	}
+	return e.Variants[len(e.Variants)-1].Version
+}
+
+// RenderFor renders name for a client: the variant assigned by the
+// template's experiment, or the active version when there is none.
+func (r *Registry) RenderFor(name, clientID string, data interface{}) (Rendered, error) {
+	e, ok := r.experiments[name]
+	if !ok {
+		return r.Render(name, "", data)
+	}
+	rendered, err := r.Render(name, e.assign(clientID), data)
+	if err != nil {
+		return Rendered{}, err
+	}
+	rendered.Experiment = e.Name
+	return rendered, nil
+}
+
+// ClientID identifies the caller for sticky assignment: the X-Client-ID
+// header when the frontend sends one, otherwise the client's IP address.
+func ClientID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get("X-Client-ID")); id != "" {
+		return id
+	}
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,227 @@
+// Package prompts renders the prompt bodies sent to the model from
+// text/template files. Templates are embedded in the binary and may be
+// replaced or extended from the directory named by PROMPT_TEMPLATE_DIR,
+// using the same <name>/<version>.tmpl layout.
+package prompts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// Template is one version of a named prompt.
+type Template struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Active marks the version rendered when none is requested.
+	Active bool `json:"active"`
+	// Source is "embedded" or "override".
+	Source string `json:"source"`
+	// Hash identifies the template text, so edits of an override without
+	// a new version are still visible.
+	Hash string `json:"hash"`
+
+	tmpl *template.Template
+}
+
+// ID is the version identifier recorded with responses, e.g. "analyze/v1".
+func (t *Template) ID() string {
+	return t.Name + "/" + t.Version
+}
+
+// Rendered is a prompt ready to send.
+type Rendered struct {
+	Text string
+	// Version is the ID of the template that produced Text.
+	Version string
+	// Experiment names the experiment that chose Version, if any.
+	Experiment string
+}
+
+// Registry holds every template version by name.
+type Registry struct {
+	templates   map[string]map[string]*Template
+	active      map[string]string
+	experiments map[string]*Experiment
+}
+
+// Load reads the embedded templates and then overrideDir, if not empty.
+// The active version of a name is the highest one, unless active names it.
+func Load(overrideDir string, active map[string]string) (*Registry, error) {
+	r := &Registry{templates: map[string]map[string]*Template{}, active: map[string]string{}}
+
+	sub, err := fs.Sub(embedded, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(sub, "embedded"); err != nil {
+		return nil, err
+	}
+	if overrideDir != "" {
+		if err := r.load(os.DirFS(overrideDir), "override"); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, versions := range r.templates {
+		r.active[name] = latest(versions)
+	}
+	for name, version := range active {
+		if _, ok := r.templates[name][version]; !ok {
+			return nil, fmt.Errorf("active prompt version %s/%s does not exist", name, version)
+		}
+		r.active[name] = version
+	}
+	for name, version := range r.active {
+		r.templates[name][version].Active = true
+	}
+	return r, nil
+}
+
+func (r *Registry) load(fsys fs.FS, source string) error {
+	files, err := fs.Glob(fsys, "*/*.tmpl")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt template %s: %v", file, err)
+		}
+		name := path.Dir(file)
+		version := strings.TrimSuffix(path.Base(file), ".tmpl")
+
+		tmpl, err := template.New(file).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("failed to parse prompt template %s: %v", file, err)
+		}
+		sum := sha256.Sum256(data)
+
+		if r.templates[name] == nil {
+			r.templates[name] = map[string]*Template{}
+		}
+		r.templates[name][version] = &Template{
+			Name:    name,
+			Version: version,
+			Source:  source,
+			Hash:    hex.EncodeToString(sum[:4]),
+			tmpl:    tmpl,
+		}
+	}
+	return nil
+}
+
+// latest picks the highest version, comparing "v<N>" numerically.
+func latest(versions map[string]*Template) string {
+	best := ""
+	for v := range versions {
+		if best == "" || versionLess(best, v) {
+			best = v
+		}
+	}
+	return best
+}
+
+func versionLess(a, b string) bool {
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, "v"))
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
+
+// List returns every template version sorted by name and version.
+func (r *Registry) List() []Template {
+	var out []Template
+	for _, versions := range r.templates {
+		for _, t := range versions {
+			out = append(out, *t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return versionLess(out[i].Version, out[j].Version)
+	})
+	return out
+}
+
+// Get returns a template version; an empty version means the active one.
+func (r *Registry) Get(name, version string) (*Template, error) {
+	versions, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt template %q", name)
+	}
+	if version == "" {
+		version = r.active[name]
+	}
+	t, ok := versions[version]
+	if !ok {
+		return nil, fmt.Errorf("unknown version %q of prompt template %q", version, name)
+	}
+	return t, nil
+}
+
+// Render executes a template version with data.
+func (r *Registry) Render(name, version string, data interface{}) (Rendered, error) {
+	t, err := r.Get(name, version)
+	if err != nil {
+		return Rendered{}, err
+	}
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return Rendered{}, fmt.Errorf("failed to render prompt %s: %v", t.ID(), err)
+	}
+	return Rendered{Text: strings.TrimSpace(buf.String()), Version: t.ID()}, nil
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultRegistry *Registry
+	defaultErr      error
+)
+
+// Default returns the process-wide Registry. Overrides come from
+// PROMPT_TEMPLATE_DIR, active versions from PROMPT_VERSIONS, e.g.
+// "analyze=v2,daily=v1", and experiments from PROMPT_EXPERIMENTS_FILE.
+func Default() (*Registry, error) {
+	defaultOnce.Do(func() {
+		active := map[string]string{}
+		for _, item := range strings.Split(os.Getenv("PROMPT_VERSIONS"), ",") {
+			name, version, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if ok {
+				active[strings.TrimSpace(name)] = strings.TrimSpace(version)
+			}
+		}
+		defaultRegistry, defaultErr = Load(os.Getenv("PROMPT_TEMPLATE_DIR"), active)
+		if defaultErr != nil {
+			return
+		}
+		if file := os.Getenv("PROMPT_EXPERIMENTS_FILE"); file != "" {
+			experiments, err := LoadExperiments(file)
+			if err == nil {
+				err = defaultRegistry.SetExperiments(experiments)
+			}
+			if err != nil {
+				defaultRegistry, defaultErr = nil, err
+			}
+		}
+	})
+	return defaultRegistry, defaultErr
+}
@@ -0,0 +1,126 @@
+package tradeplan
+
+import (
+	"fmt"
+
+	"stock-analysis-api/idx"
+)
+
+// Trader profile limits the plan is checked against, in percent.
+const (
+	// Target1Pct and Target2Pct are the profit targets above the entry.
+	Target1Pct = 4.0
+	Target2Pct = 5.0
+	// targetTolerance is how far, in percentage points, a target may sit
+	// outside Target1Pct-Target2Pct before it is repaired.
+	targetTolerance = 0.5
+	// StopPct is where a missing or misplaced stop is put below the entry
+	// zone, keeping the risk under the first target's reward.
+	StopPct = 3.0
+	// MaxPositionPct caps a single position as a share of capital.
+	MaxPositionPct = 30.0
+)
+
+// Warning is one rule the model's plan broke and how it was repaired.
+// Original and Repaired are the field's values before and after; Repaired
+// equals Original when the plan could not be fixed.
+type Warning struct {
+	Field    string  `json:"field"`
+	Message  string  `json:"message"`
+	Original float64 `json:"original"`
+	Repaired float64 `json:"repaired"`
+}
+
+// Repair checks a BUY plan against the trader profile and returns a
+// corrected copy with one Warning per change. It expects levels already on
+// ticks (see RoundToTicks) and puts repaired levels on ticks too, so the
+// checks hold for the prices that are actually returned:
+//   - the entry zone must be positive, with Low <= High;
+//   - Target1 and Target2 must be Target1Pct and Target2Pct (within
+//     tolerance) above Entry.High, so they are reached from any fill in the
+//     zone, and Target1 <= Target2;
+//   - StopLoss must be positive and below Entry.Low;
+//   - PositionSize must be positive and at most MaxPositionPct of capital.
+//
+// Plans that are not BUY are returned unchanged.
+func Repair(p *TradePlan, capital float64) (*TradePlan, []Warning) {
+	fixed := *p
+	if fixed.Decision != DecisionBuy {
+		return &fixed, nil
+	}
+	var warnings []Warning
+	warn := func(field, msg string, original, repaired float64) {
+		warnings = append(warnings, Warning{Field: field, Message: msg, Original: original, Repaired: repaired})
+	}
+
+	if fixed.Entry.Low > fixed.Entry.High {
+		low, high := fixed.Entry.High, fixed.Entry.Low
+		warn("entry.low", "entry low is above entry high; swapped", fixed.Entry.Low, low)
+		warn("entry.high", "entry high is below entry low; swapped", fixed.Entry.High, high)
+		fixed.Entry.Low, fixed.Entry.High = low, high
+	}
+	if fixed.Entry.Low <= 0 {
+		// Every other level is measured from the entry, so there is
+		// nothing to repair against.
+		warn("entry", "entry zone is missing; price levels were not checked", fixed.Entry.Low, fixed.Entry.Low)
+	} else {
+		entry := fixed.Entry.High
+		lo := entry * (1 + (Target1Pct-targetTolerance)/100)
+		hi := entry * (1 + (Target2Pct+targetTolerance)/100)
+
+		if fixed.Target2 > 0 && fixed.Target1 > fixed.Target2 {
+			warn("target_1", "target 1 is above target 2; swapped", fixed.Target1, fixed.Target2)
+			fixed.Target1, fixed.Target2 = fixed.Target2, fixed.Target1
+		}
+		if fixed.Target1 < lo || fixed.Target1 > hi {
+			repaired := tickWithin(entry*(1+Target1Pct/100), lo)
+			warn("target_1", targetMessage("target 1", fixed.Target1, entry, Target1Pct), fixed.Target1, repaired)
+			fixed.Target1 = repaired
+		}
+		if fixed.Target2 < max(lo, fixed.Target1) || fixed.Target2 > hi {
+			repaired := tickWithin(entry*(1+Target2Pct/100), fixed.Target1)
+			warn("target_2", targetMessage("target 2", fixed.Target2, entry, Target2Pct), fixed.Target2, repaired)
+			fixed.Target2 = repaired
+		}
+
+		if fixed.StopLoss <= 0 || fixed.StopLoss >= fixed.Entry.Low {
+			repaired := idx.RoundUp(fixed.Entry.Low * (1 - StopPct/100))
+			msg := "stop loss is not below the entry zone"
+			if fixed.StopLoss <= 0 {
+				msg = "stop loss is missing"
+			}
+			warn("stop_loss", fmt.Sprintf("%s; set %.0f%% below entry low", msg, StopPct), fixed.StopLoss, repaired)
+			fixed.StopLoss = repaired
+		}
+	}
+
+	maxPosition := capital * MaxPositionPct / 100
+	switch {
+	case fixed.PositionSize <= 0:
+		warn("position_size", fmt.Sprintf("position size is missing; set to the %.0f%% maximum", MaxPositionPct), fixed.PositionSize, maxPosition)
+		fixed.PositionSize = maxPosition
+	case fixed.PositionSize > maxPosition:
+		warn("position_size", fmt.Sprintf("position size is %.0f%% of capital, above the %.0f%% maximum", fixed.PositionSize/capital*100, MaxPositionPct), fixed.PositionSize, maxPosition)
+		fixed.PositionSize = maxPosition
+	}
+
+	return &fixed, warnings
+}
+
+// tickWithin puts a repaired target on a tick: rounded down, so it stays
+// reachable, unless that falls below floor. One tick is at most 1% of the
+// price, so either way the target stays inside the profile's band.
+func tickWithin(price, floor float64) float64 {
+	if down := idx.RoundDown(price); down >= floor {
+		return down
+	}
+	return idx.RoundUp(price)
+}
+
+// targetMessage describes a target outside the profile's band.
+func targetMessage(name string, price, entry, pct float64) string {
+	if price <= 0 {
+		return fmt.Sprintf("%s is missing; set %.0f%% above entry high", name, pct)
+	}
+	return fmt.Sprintf("%s is %.1f%% above entry high, not %.0f%%", name, (price-entry)/entry*100, pct)
+}
@@ -0,0 +1,207 @@
+package tradeplan
+
+import (
+	"reflect"
+	"testing"
+
+	"stock-analysis-api/idx"
+)
+
+func TestRepair(t *testing.T) {
+	const capital = 7_500_000
+	valid := TradePlan{
+		Decision:     DecisionBuy,
+		Entry:        PriceRange{Low: 1400, High: 1450},
+		Target1:      1505,
+		Target2:      1520,
+		StopLoss:     1360,
+		PositionSize: 2_000_000,
+	}
+	with := func(change func(p *TradePlan)) TradePlan {
+		p := valid
+		change(&p)
+		return p
+	}
+
+	tests := []struct {
+		name     string
+		plan     TradePlan
+		want     TradePlan
+		warnings []Warning
+	}{
+		{name: "valid plan", plan: valid, want: valid},
+		{
+			name: "not a BUY",
+			plan: TradePlan{Decision: DecisionHold, Target1: 1},
+			want: TradePlan{Decision: DecisionHold, Target1: 1},
+		},
+		{
+			name: "entry swapped",
+			plan: with(func(p *TradePlan) { p.Entry = PriceRange{Low: 1450, High: 1400} }),
+			want: valid,
+			warnings: []Warning{
+				{Field: "entry.low", Original: 1450, Repaired: 1400},
+				{Field: "entry.high", Original: 1400, Repaired: 1450},
+			},
+		},
+		{
+			name:     "entry missing",
+			plan:     with(func(p *TradePlan) { p.Entry = PriceRange{} }),
+			want:     with(func(p *TradePlan) { p.Entry = PriceRange{} }),
+			warnings: []Warning{{Field: "entry", Original: 0, Repaired: 0}},
+		},
+		{
+			name:     "target 1 missing",
+			plan:     with(func(p *TradePlan) { p.Target1 = 0 }),
+			want:     valid,
+			warnings: []Warning{{Field: "target_1", Original: 0, Repaired: 1505}},
+		},
+		{
+			name:     "target 1 too close",
+			plan:     with(func(p *TradePlan) { p.Target1 = 1490 }),
+			want:     valid,
+			warnings: []Warning{{Field: "target_1", Original: 1490, Repaired: 1505}},
+		},
+		{
+			name: "target 1 above a target 2 that is too far",
+			plan: with(func(p *TradePlan) { p.Target1, p.Target2 = 1600, 1520 }),
+			want: with(func(p *TradePlan) { p.Target1 = 1520 }),
+			warnings: []Warning{
+				{Field: "target_1", Original: 1600, Repaired: 1520},
+				{Field: "target_2", Original: 1600, Repaired: 1520},
+			},
+		},
+		{
+			name:     "targets swapped",
+			plan:     with(func(p *TradePlan) { p.Target1, p.Target2 = 1520, 1505 }),
+			want:     valid,
+			warnings: []Warning{{Field: "target_1", Original: 1520, Repaired: 1505}},
+		},
+		{
+			name: "target 2 below the band",
+			plan: with(func(p *TradePlan) { p.Target2 = 1490 }),
+			want: with(func(p *TradePlan) { p.Target2 = 1505 }),
+			warnings: []Warning{
+				{Field: "target_1", Original: 1505, Repaired: 1490},
+				{Field: "target_1", Original: 1490, Repaired: 1505},
+			},
+		},
+		{
+			name:     "target 2 below target 1",
+			plan:     with(func(p *TradePlan) { p.Target1, p.Target2 = 1525, 1510 }),
+			want:     with(func(p *TradePlan) { p.Target1, p.Target2 = 1510, 1525 }),
+			warnings: []Warning{{Field: "target_1", Original: 1525, Repaired: 1510}},
+		},
+		{
+			name:     "target 2 too far",
+			plan:     with(func(p *TradePlan) { p.Target2 = 1600 }),
+			want:     valid,
+			warnings: []Warning{{Field: "target_2", Original: 1600, Repaired: 1520}},
+		},
+		{
+			// 4% above 490 is 509.6, which rounds down to 505, below the
+			// 3.5% floor of 507.15; the repair rounds up instead.
+			name: "repaired target rounded up to stay in the band",
+			plan: TradePlan{
+				Decision: DecisionBuy, Entry: PriceRange{Low: 480, High: 490},
+				Target2: 510, StopLoss: 466, PositionSize: 1_000_000,
+			},
+			want: TradePlan{
+				Decision: DecisionBuy, Entry: PriceRange{Low: 480, High: 490},
+				Target1: 510, Target2: 510, StopLoss: 466, PositionSize: 1_000_000,
+			},
+			warnings: []Warning{{Field: "target_1", Original: 0, Repaired: 510}},
+		},
+		{
+			name:     "stop missing",
+			plan:     with(func(p *TradePlan) { p.StopLoss = 0 }),
+			want:     valid,
+			warnings: []Warning{{Field: "stop_loss", Original: 0, Repaired: 1360}},
+		},
+		{
+			name:     "stop inside the entry zone",
+			plan:     with(func(p *TradePlan) { p.StopLoss = 1420 }),
+			want:     valid,
+			warnings: []Warning{{Field: "stop_loss", Original: 1420, Repaired: 1360}},
+		},
+		{
+			name:     "position missing",
+			plan:     with(func(p *TradePlan) { p.PositionSize = 0 }),
+			want:     with(func(p *TradePlan) { p.PositionSize = 2_250_000 }),
+			warnings: []Warning{{Field: "position_size", Original: 0, Repaired: 2_250_000}},
+		},
+		{
+			name:     "position too large",
+			plan:     with(func(p *TradePlan) { p.PositionSize = 5_000_000 }),
+			want:     with(func(p *TradePlan) { p.PositionSize = 2_250_000 }),
+			warnings: []Warning{{Field: "position_size", Original: 5_000_000, Repaired: 2_250_000}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.plan
+			got, warnings := Repair(&tt.plan, capital)
+			if !reflect.DeepEqual(tt.plan, original) {
+				t.Errorf("Repair changed its argument to %+v", tt.plan)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("plan = %+v\nwant   %+v", *got, tt.want)
+			}
+
+			if len(warnings) != len(tt.warnings) {
+				t.Fatalf("warnings = %+v, want %+v", warnings, tt.warnings)
+			}
+			for i, w := range warnings {
+				want := tt.warnings[i]
+				if w.Field != want.Field || w.Original != want.Original || w.Repaired != want.Repaired || w.Message == "" {
+					t.Errorf("warning %d = %+v, want %+v", i, w, want)
+				}
+			}
+
+			if got.Decision == DecisionBuy && got.Entry.Low > 0 {
+				for _, level := range []float64{got.Entry.Low, got.Entry.High, got.Target1, got.Target2, got.StopLoss} {
+					if !idx.ValidTick(level) {
+						t.Errorf("level %v is not on a tick", level)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestRoundThenRepair checks the order used by the analyze endpoint: the
+// model's levels are rounded first, and a stop the model put inside the
+// entry zone is still reported rather than silently moved by rounding.
+func TestRoundThenRepair(t *testing.T) {
+	plan := &TradePlan{
+		Decision:     DecisionBuy,
+		Entry:        PriceRange{Low: 1402, High: 1453},
+		Target1:      1482,
+		Target2:      1526,
+		StopLoss:     1460,
+		PositionSize: 2_000_000,
+	}
+	plan.RoundToTicks()
+	got, warnings := Repair(plan, 7_500_000)
+
+	want := PlanLevels{Entry: PriceRange{Low: 1400, High: 1450}, Target1: 1505, Target2: 1525, StopLoss: 1360}
+	if levels := (PlanLevels{Entry: got.Entry, Target1: got.Target1, Target2: got.Target2, StopLoss: got.StopLoss}); levels != want {
+		t.Errorf("levels = %+v, want %+v", levels, want)
+	}
+	wantWarnings := []Warning{
+		{Field: "target_1", Original: 1480, Repaired: 1505},
+		{Field: "stop_loss", Original: 1460, Repaired: 1360},
+	}
+	if len(warnings) != len(wantWarnings) {
+		t.Fatalf("warnings = %+v, want %+v", warnings, wantWarnings)
+	}
+	for i, w := range warnings {
+		if want := wantWarnings[i]; w.Field != want.Field || w.Original != want.Original || w.Repaired != want.Repaired {
+			t.Errorf("warning %d = %+v, want %+v", i, w, want)
+		}
+	}
+	if got.Unrounded == nil || got.Unrounded.StopLoss != 1460 {
+		t.Errorf("unrounded = %+v, want the model's levels", got.Unrounded)
+	}
+}
@@ -0,0 +1,165 @@
+package tradeplan
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"stock-analysis-api/idx"
+	"stock-analysis-api/llm"
+)
+
+// Pick categories, one per slot of the daily prompt.
+const (
+	CategoryBlueChip = "BLUE_CHIP"
+	CategoryGrowth   = "GROWTH"
+	CategoryRecovery = "RECOVERY"
+	CategoryMomentum = "MOMENTUM"
+)
+
+// MarketBriefing is the IHSG and sector overview of the daily picks.
+type MarketBriefing struct {
+	IHSGLevel              float64  `json:"ihsg_level"`
+	Trend                  string   `json:"trend"`
+	Resistance             float64  `json:"resistance"`
+	Support                float64  `json:"support"`
+	OutperformingSectors   []string `json:"outperforming_sectors"`
+	UnderperformingSectors []string `json:"underperforming_sectors"`
+	ForeignFlow            string   `json:"foreign_flow"`
+}
+
+// Pick is one trading opportunity. Prices and Size are in rupiah.
+type Pick struct {
+	Category  string     `json:"category"`
+	StockCode string     `json:"stock_code"`
+	Price     float64    `json:"price"`
+	WhyNow    string     `json:"why_now"`
+	Entry     PriceRange `json:"entry"`
+	Target    float64    `json:"target"`
+	StopLoss  float64    `json:"stop_loss"`
+	Size      float64    `json:"size"`
+	Risk      string     `json:"risk"`
+	// Unrounded holds the levels as the model gave them once the pick was
+	// rounded to IDX ticks by RoundToTicks.
+	Unrounded *PickLevels `json:"unrounded,omitempty"`
+	// Position is Size in whole lots, set by SizeLots.
+	Position *idx.Position `json:"position,omitempty"`
+	// Limits are the session's ARA/ARB bounds from the previous close and
+	// LimitFlags the levels outside them, set by CheckLimits.
+	Limits     *idx.Limits `json:"limits,omitempty"`
+	LimitFlags []LimitFlag `json:"limit_flags,omitempty"`
+}
+
+// Allocation summarizes how much of Capital the picks deploy. It is
+// computed by the server from the pick sizes, not taken from the model.
+type Allocation struct {
+	Capital     float64 `json:"capital"`
+	Deployed    float64 `json:"deployed"`
+	CashReserve float64 `json:"cash_reserve"`
+	// WithinCapital is false when the picks add up to more than Capital.
+	WithinCapital bool `json:"within_capital"`
+}
+
+// DailyPicks is the structured form of the daily recommendations.
+type DailyPicks struct {
+	MarketBriefing MarketBriefing `json:"market_briefing"`
+	Picks          []Pick         `json:"picks"`
+	Allocation     Allocation     `json:"allocation"`
+}
+
+// DailySchema is the response schema for DailyPicks. Allocation is left
+// out because the server computes it.
+func DailySchema() *llm.Schema {
+	rupiah := func(desc string) *llm.Schema {
+		return &llm.Schema{Type: llm.TypeNumber, Description: desc}
+	}
+	names := &llm.Schema{Type: llm.TypeArray, Items: &llm.Schema{Type: llm.TypeString}}
+	return &llm.Schema{
+		Type: llm.TypeObject,
+		Properties: map[string]*llm.Schema{
+			"market_briefing": {
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"ihsg_level":              {Type: llm.TypeNumber},
+					"trend":                   {Type: llm.TypeString, Enum: []string{"Bullish", "Bearish", "Sideways"}},
+					"resistance":              {Type: llm.TypeNumber},
+					"support":                 {Type: llm.TypeNumber},
+					"outperforming_sectors":   names,
+					"underperforming_sectors": names,
+					"foreign_flow":            {Type: llm.TypeString},
+				},
+				Required: []string{"ihsg_level", "trend", "resistance", "support"},
+			},
+			"picks": {
+				Type: llm.TypeArray,
+				Items: &llm.Schema{
+					Type: llm.TypeObject,
+					Properties: map[string]*llm.Schema{
+						"category": {
+							Type: llm.TypeString,
+							Enum: []string{CategoryBlueChip, CategoryGrowth, CategoryRecovery, CategoryMomentum},
+						},
+						"stock_code": {Type: llm.TypeString, Description: "IDX ticker, e.g. BBCA"},
+						"price":      rupiah("Current price in rupiah"),
+						"why_now":    {Type: llm.TypeString},
+						"entry": {
+							Type: llm.TypeObject,
+							Properties: map[string]*llm.Schema{
+								"low":  rupiah("Lower bound of the entry zone in rupiah"),
+								"high": rupiah("Upper bound of the entry zone in rupiah"),
+							},
+							Required: []string{"low", "high"},
+						},
+						"target":    rupiah("Target price in rupiah"),
+						"stop_loss": rupiah("Stop loss level in rupiah"),
+						"size":      rupiah("Amount to deploy in rupiah"),
+						"risk":      {Type: llm.TypeString},
+					},
+					Required: []string{"category", "stock_code", "price", "entry", "target", "stop_loss", "size", "risk"},
+				},
+			},
+		},
+		Required: []string{"market_briefing", "picks"},
+	}
+}
+
+// StructureDaily transcribes the daily recommendations into DailyPicks and
+// fills in the allocation for capital.
+func StructureDaily(ctx context.Context, client *llm.Client, recommendations string, capital float64) (*DailyPicks, *llm.Response, error) {
+	prompt := `Ubah daily picks berikut menjadi JSON dengan field:
+market_briefing {ihsg_level, trend, resistance, support, outperforming_sectors,
+underperforming_sectors, foreign_flow} dan picks (array) dengan field category
+(BLUE_CHIP/GROWTH/RECOVERY/MOMENTUM), stock_code, price, why_now,
+entry {low, high}, target, stop_loss, size, risk.
+
+Semua harga dan size dalam rupiah sebagai angka tanpa pemisah ribuan.
+Jangan menambah pick atau angka yang tidak ada di teks.
+
+DAILY PICKS:
+` + recommendations
+
+	var picks DailyPicks
+	resp, err := structure(ctx, client, prompt, DailySchema(), &picks)
+	if err != nil {
+		return nil, resp, err
+	}
+	if len(picks.Picks) == 0 {
+		return nil, resp, fmt.Errorf("invalid daily picks: no picks found")
+	}
+	picks.Allocation = Allocate(picks.Picks, capital)
+	return &picks, resp, nil
+}
+
+// Allocate sums the pick sizes against capital.
+func Allocate(picks []Pick, capital float64) Allocation {
+	var deployed float64
+	for _, p := range picks {
+		deployed += p.Size
+	}
+	return Allocation{
+		Capital:       capital,
+		Deployed:      deployed,
+		CashReserve:   math.Max(capital-deployed, 0),
+		WithinCapital: deployed <= capital,
+	}
+}
@@ -0,0 +1,298 @@
+package tradeplan
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Extraction is a TradePlan read from the markdown of an analysis without
+// calling the model. Missing lists, by JSON name, the fields that were not
+// found or could not be parsed; their values in Plan are zero.
+type Extraction struct {
+	Plan    TradePlan `json:"plan"`
+	Missing []string  `json:"missing,omitempty"`
+}
+
+// Complete reports whether every field was extracted.
+func (e *Extraction) Complete() bool {
+	return len(e.Missing) == 0
+}
+
+var (
+	// sectionHeader matches a bold all-caps heading such as
+	// "**RISK FACTORS**".
+	sectionHeader = regexp.MustCompile(`^\*\*([A-Z][A-Z &/-]+)\*\*:?$`)
+
+	labelDecision   = regexp.MustCompile(`(?i)^(entry\s+)?decision\b[^:]*:`)
+	labelEntry      = regexp.MustCompile(`(?i)^entry(\s+(zone|price|range|area|point))?\s*(\([^)]*\))?\s*:`)
+	labelTarget1    = regexp.MustCompile(`(?i)^(target|tp)\s*1\b[^:]*:`)
+	labelTarget2    = regexp.MustCompile(`(?i)^(target|tp)\s*2\b[^:]*:`)
+	labelStopLoss   = regexp.MustCompile(`(?i)^(stop[\s-]*loss|sl)\b[^:]*:`)
+	labelPosition   = regexp.MustCompile(`(?i)^position(\s+size)?\s*(\([^)]*\))?\s*:`)
+	labelTimeline   = regexp.MustCompile(`(?i)^timeline\b[^:]*:`)
+	labelConfidence = regexp.MustCompile(`(?i)^confidence\b[^:]*:`)
+
+	decisionWord = regexp.MustCompile(`(?i)\b(BUY|HOLD|AVOID|BELI|TAHAN|HINDARI)\b`)
+
+	// amount is a number with Indonesian or English separators and an
+	// optional scale, e.g. "1.450", "1,400", "7,5 juta" or "2.25M". A
+	// trailing "%" marks a percentage, which is skipped.
+	amount = regexp.MustCompile(`(?i)(\d[\d.,]*)(\s*(juta|jt|ribu|rb|k|m)\b)?(\s*%)?`)
+
+	// rupiahPrefix ends text that introduces a price, e.g. "Rp " or "Rp.".
+	rupiahPrefix = regexp.MustCompile(`(?i)\bRp\.?\s*$`)
+	// rangeSeparator is the text between the two ends of a price range,
+	// e.g. " - ", "–", " s/d Rp ".
+	rangeSeparator = regexp.MustCompile(`(?i)^\s*(-|–|—|~|s/d|sampai|hingga|to)\s*(Rp\.?\s*)?$`)
+)
+
+var decisionAliases = map[string]string{
+	"BUY":     DecisionBuy,
+	"BELI":    DecisionBuy,
+	"HOLD":    DecisionHold,
+	"TAHAN":   DecisionHold,
+	"AVOID":   DecisionAvoid,
+	"HINDARI": DecisionAvoid,
+}
+
+// Extract parses the **TRADING RECOMMENDATION** section of a free-text
+// analysis. It reads from that heading to the end of the text, because the
+// confidence line usually comes last; without the heading the whole text is
+// read. The first line carrying each label wins. Price levels, position
+// size and timeline are only expected when the decision is BUY.
+func Extract(markdown string) *Extraction {
+	lines := strings.Split(markdown, "\n")
+	start := 0
+	for i, line := range lines {
+		if strings.Contains(strings.ToUpper(line), "TRADING RECOMMENDATION") {
+			start = i
+			break
+		}
+	}
+
+	ext := &Extraction{Plan: TradePlan{RiskFactors: []string{}}}
+	p := &ext.Plan
+	found := map[string]bool{}
+	section := ""
+
+	for _, raw := range lines[start:] {
+		line := cleanLine(raw)
+		if line == "" {
+			continue
+		}
+		if m := sectionHeader.FindStringSubmatch(strings.TrimSpace(raw)); m != nil {
+			section = m[1]
+			continue
+		}
+		if section == "RISK FACTORS" && isBullet(raw) {
+			p.RiskFactors = append(p.RiskFactors, line)
+			continue
+		}
+
+		switch {
+		case !found["decision"] && labelDecision.MatchString(line):
+			if m := decisionWord.FindString(value(labelDecision, line)); m != "" {
+				p.Decision = decisionAliases[strings.ToUpper(m)]
+				found["decision"] = true
+			}
+		case !found["entry"] && labelEntry.MatchString(line):
+			if levels := entryLevels(value(labelEntry, line)); len(levels) > 0 {
+				sort.Float64s(levels)
+				p.Entry = PriceRange{Low: levels[0], High: levels[len(levels)-1]}
+				found["entry"] = true
+			}
+		case !found["target_1"] && labelTarget1.MatchString(line):
+			found["target_1"] = firstAmount(value(labelTarget1, line), &p.Target1)
+		case !found["target_2"] && labelTarget2.MatchString(line):
+			found["target_2"] = firstAmount(value(labelTarget2, line), &p.Target2)
+		case !found["stop_loss"] && labelStopLoss.MatchString(line):
+			found["stop_loss"] = firstAmount(value(labelStopLoss, line), &p.StopLoss)
+		case !found["position_size"] && labelPosition.MatchString(line):
+			found["position_size"] = firstAmount(value(labelPosition, line), &p.PositionSize)
+		case !found["timeline"] && labelTimeline.MatchString(line):
+			if v := value(labelTimeline, line); v != "" && !isPlaceholder(v) {
+				p.Timeline = v
+				found["timeline"] = true
+			}
+		case !found["confidence"] && labelConfidence.MatchString(line):
+			var v float64
+			if firstAmount(value(labelConfidence, line), &v) && v >= 1 && v <= 10 {
+				p.Confidence = int(v)
+				found["confidence"] = true
+			}
+		}
+	}
+
+	expected := []string{"decision"}
+	if p.Decision == DecisionBuy || p.Decision == "" {
+		expected = append(expected, "entry", "target_1", "target_2", "stop_loss", "position_size", "timeline")
+	}
+	expected = append(expected, "confidence")
+	for _, name := range expected {
+		if !found[name] {
+			ext.Missing = append(ext.Missing, name)
+		}
+	}
+	return ext
+}
+
+// cleanLine drops list markers, bold markers and surrounding space.
+func cleanLine(line string) string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimLeft(line, "-*•> ")
+	line = strings.ReplaceAll(line, "**", "")
+	return strings.TrimSpace(line)
+}
+
+func isBullet(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "-") || strings.HasPrefix(line, "*") && !strings.HasPrefix(line, "**") || strings.HasPrefix(line, "•")
+}
+
+// value returns what follows the label matched by re.
+func value(re *regexp.Regexp, line string) string {
+	return strings.TrimSpace(line[len(re.FindString(line)):])
+}
+
+// isPlaceholder reports template text the model left unfilled, such as
+// "[1-3 days]", or an explicit blank.
+func isPlaceholder(v string) bool {
+	if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+		return true
+	}
+	switch strings.ToUpper(v) {
+	case "-", "N/A", "NA", "TBD":
+		return true
+	}
+	return false
+}
+
+func firstAmount(v string, dst *float64) bool {
+	if isPlaceholder(v) {
+		return false
+	}
+	levels := amounts(v)
+	if len(levels) == 0 {
+		return false
+	}
+	*dst = levels[0]
+	return true
+}
+
+// amounts returns the rupiah amounts in v, skipping percentages.
+func amounts(v string) []float64 {
+	var out []float64
+	for _, m := range amount.FindAllStringSubmatch(v, -1) {
+		if n, ok := amountValue(m); ok {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// entryLevels returns the prices in an entry zone. Only amounts written as
+// prices count: after "Rp", at either end of a range such as "1.400 -
+// 1.450", or as the whole value. Numbers in indicator names like "MA20" are
+// skipped.
+func entryLevels(v string) []float64 {
+	matches := amount.FindAllStringSubmatchIndex(v, -1)
+	ranged := make([]bool, len(matches))
+	for i := 1; i < len(matches); i++ {
+		if rangeSeparator.MatchString(v[matches[i-1][1]:matches[i][0]]) {
+			ranged[i-1], ranged[i] = true, true
+		}
+	}
+
+	var out []float64
+	for i, m := range matches {
+		before := v[:m[0]]
+		rupiah := rupiahPrefix.MatchString(before)
+		whole := len(matches) == 1 && strings.TrimSpace(before) == "" && strings.TrimSpace(v[m[1]:]) == ""
+		if !rupiah && (gluedToWord(before) || !ranged[i] && !whole) {
+			continue
+		}
+		groups := make([]string, len(m)/2)
+		for g := range groups {
+			if m[2*g] >= 0 {
+				groups[g] = v[m[2*g]:m[2*g+1]]
+			}
+		}
+		if n, ok := amountValue(groups); ok {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// gluedToWord reports whether the number after before is part of a word,
+// as in "MA20".
+func gluedToWord(before string) bool {
+	r, _ := utf8.DecodeLastRuneInString(before)
+	return unicode.IsLetter(r)
+}
+
+// amountValue converts a match of amount, skipping percentages.
+func amountValue(m []string) (float64, bool) {
+	if m[4] != "" {
+		return 0, false
+	}
+	n, ok := parseNumber(m[1])
+	if !ok {
+		return 0, false
+	}
+	switch strings.ToLower(m[3]) {
+	case "juta", "jt", "m":
+		n *= 1_000_000
+	case "ribu", "rb", "k":
+		n *= 1_000
+	}
+	return n, true
+}
+
+// parseNumber reads a number written with either convention: "1.450" and
+// "1,450" are both 1450, "1.450,5" and "1,450.5" are 1450.5, and "7,5" is
+// 7.5. A single separator followed by exactly three digits is taken as a
+// thousands separator.
+func parseNumber(s string) (float64, bool) {
+	s = strings.TrimRight(s, ".,")
+	if s == "" {
+		return 0, false
+	}
+
+	lastDot := strings.LastIndex(s, ".")
+	lastComma := strings.LastIndex(s, ",")
+	decimal := -1
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		decimal = lastDot
+		if lastComma > lastDot {
+			decimal = lastComma
+		}
+	case lastDot >= 0 || lastComma >= 0:
+		sep := "."
+		at := lastDot
+		if lastComma >= 0 {
+			sep, at = ",", lastComma
+		}
+		if strings.Count(s, sep) == 1 && len(s)-at-1 != 3 {
+			decimal = at
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case i == decimal:
+			b.WriteByte('.')
+		case r == '.' || r == ',':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	n, err := strconv.ParseFloat(b.String(), 64)
+	return n, err == nil
+}
@@ -0,0 +1,219 @@
+package tradeplan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   float64
+		wantOK bool
+	}{
+		{"1450", 1450, true},
+		{"1.450", 1450, true},
+		{"1,450", 1450, true},
+		{"1.450,5", 1450.5, true},
+		{"1,450.5", 1450.5, true},
+		{"7,5", 7.5, true},
+		{"2.25", 2.25, true},
+		{"1.234.567", 1234567, true},
+		{"1,234,567", 1234567, true},
+		{"1.450.", 1450, true},
+		{".,", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseNumber(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseNumber(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestAmounts(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []float64
+	}{
+		{"Rp 1.450", []float64{1450}},
+		{"1,400-1,450", []float64{1400, 1450}},
+		{"Rp 1.400 - Rp 1.450", []float64{1400, 1450}},
+		{"7,5 juta", []float64{7_500_000}},
+		{"Rp 3jt", []float64{3_000_000}},
+		{"500 ribu", []float64{500_000}},
+		{"2.25M", []float64{2_250_000}},
+		{"1.450,5", []float64{1450.5}},
+		{"Rp 1.508 (+4%)", []float64{1508}},
+		{"30% dari modal", nil},
+		{"[1-3 days]", []float64{1, 3}},
+		{"N/A", nil},
+	}
+	for _, tt := range tests {
+		if got := amounts(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("amounts(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEntryLevels(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []float64
+	}{
+		{"Rp 1.400 - Rp 1.450", []float64{1400, 1450}},
+		{"1,400-1,450", []float64{1400, 1450}},
+		{"1.400 s/d 1.450", []float64{1400, 1450}},
+		{"Rp1.420", []float64{1420}},
+		{"1450", []float64{1450}},
+		{"dekat MA20, Rp 1.400 - 1.450", []float64{1400, 1450}},
+		{"1.400 - 1.450 (support MA20)", []float64{1400, 1450}},
+		{"pullback ke MA20", nil},
+		{"antara MA20 - MA50", nil},
+		{"breakout 1.450 dengan RSI 14", nil},
+		{"Rp 1.450 (-2%)", []float64{1450}},
+	}
+	for _, tt := range tests {
+		if got := entryLevels(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("entryLevels(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name        string
+		markdown    string
+		want        TradePlan
+		wantMissing []string
+	}{
+		{
+			name: "complete plan",
+			markdown: `**SUMMARY**
+Strong momentum.
+
+**TRADING RECOMMENDATION**
+- **Decision:** BUY
+- **Entry Zone:** Rp 1.400 - Rp 1.450
+- **Target 1:** Rp 1.508 (+4%)
+- **Target 2:** Rp 1.522 (+5%)
+- **Stop Loss:** Rp 1.358 (-3%)
+- **Position Size:** 7,5 juta (30% dari modal)
+- **Timeline:** 1-3 hari
+
+**RISK FACTORS**
+- Volume tipis
+- IHSG melemah
+
+**Confidence:** 7/10`,
+			want: TradePlan{
+				Decision:     DecisionBuy,
+				Entry:        PriceRange{Low: 1400, High: 1450},
+				Target1:      1508,
+				Target2:      1522,
+				StopLoss:     1358,
+				PositionSize: 7_500_000,
+				Timeline:     "1-3 hari",
+				RiskFactors:  []string{"Volume tipis", "IHSG melemah"},
+				Confidence:   7,
+			},
+		},
+		{
+			name: "Indonesian decision and English separators",
+			markdown: `Decision: BELI
+Entry: 1,400-1,450
+TP1: 1,508
+TP2: 1,522
+SL: 1,358
+Position: Rp 3jt
+Timeline: 2 days
+Confidence: 8`,
+			want: TradePlan{
+				Decision:     DecisionBuy,
+				Entry:        PriceRange{Low: 1400, High: 1450},
+				Target1:      1508,
+				Target2:      1522,
+				StopLoss:     1358,
+				PositionSize: 3_000_000,
+				Timeline:     "2 days",
+				RiskFactors:  []string{},
+				Confidence:   8,
+			},
+		},
+		{
+			name: "indicator names in the entry zone",
+			markdown: `Decision: BUY
+Entry: pullback ke MA20
+Entry Zone: dekat MA20, Rp 1.400 - 1.450
+Target 1: 1.508
+Target 2: 1.522
+Stop Loss: 1.358
+Position Size: 7,5 juta
+Timeline: 1-3 hari
+Confidence: 7`,
+			want: TradePlan{
+				Decision:     DecisionBuy,
+				Entry:        PriceRange{Low: 1400, High: 1450},
+				Target1:      1508,
+				Target2:      1522,
+				StopLoss:     1358,
+				PositionSize: 7_500_000,
+				Timeline:     "1-3 hari",
+				RiskFactors:  []string{},
+				Confidence:   7,
+			},
+		},
+		{
+			name: "placeholders are reported missing",
+			markdown: `**TRADING RECOMMENDATION**
+Decision: BUY
+Entry Zone: [price range]
+Target 1: [price]
+Target 2: 1.522
+Stop Loss: -
+Position Size: 30%
+Timeline: [1-3 days]
+Confidence: [1-10]`,
+			want: TradePlan{
+				Decision:    DecisionBuy,
+				Target2:     1522,
+				RiskFactors: []string{},
+			},
+			wantMissing: []string{"entry", "target_1", "stop_loss", "position_size", "timeline", "confidence"},
+		},
+		{
+			name: "levels are not expected when avoiding",
+			markdown: `Decision: HINDARI
+Confidence: 6`,
+			want: TradePlan{
+				Decision:    DecisionAvoid,
+				RiskFactors: []string{},
+				Confidence:  6,
+			},
+		},
+		{
+			name:     "no decision",
+			markdown: "Saham ini menarik.",
+			want:     TradePlan{RiskFactors: []string{}},
+			wantMissing: []string{
+				"decision", "entry", "target_1", "target_2", "stop_loss",
+				"position_size", "timeline", "confidence",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Extract(tt.markdown)
+			if !reflect.DeepEqual(got.Plan, tt.want) {
+				t.Errorf("plan = %+v\nwant   %+v", got.Plan, tt.want)
+			}
+			if !reflect.DeepEqual(got.Missing, tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", got.Missing, tt.wantMissing)
+			}
+			if got.Complete() != (len(tt.wantMissing) == 0) {
+				t.Errorf("Complete() = %v with missing %v", got.Complete(), got.Missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package tradeplan
+
+import (
+	"fmt"
+
+	"stock-analysis-api/idx"
+)
+
+// LimitFlag is a price level outside the session's auto-rejection limits,
+// so it cannot trade until a later session.
+type LimitFlag struct {
+	Field   string  `json:"field"`
+	Price   float64 `json:"price"`
+	Limit   float64 `json:"limit"`
+	Message string  `json:"message"`
+}
+
+// CheckLimits sets Limits on a BUY plan and flags its levels outside them.
+func (p *TradePlan) CheckLimits(l idx.Limits) {
+	if p.Decision != DecisionBuy {
+		return
+	}
+	p.Limits = &l
+	p.LimitFlags = limitFlags(l, p.Entry, map[string]float64{
+		"target_1": p.Target1,
+		"target_2": p.Target2,
+	}, p.StopLoss)
+}
+
+// CheckLimits does the same for a daily pick.
+func (p *Pick) CheckLimits(l idx.Limits) {
+	p.Limits = &l
+	p.LimitFlags = limitFlags(l, p.Entry, map[string]float64{"target": p.Target}, p.StopLoss)
+}
+
+func limitFlags(l idx.Limits, entry PriceRange, targets map[string]float64, stop float64) []LimitFlag {
+	var flags []LimitFlag
+	above := func(field string, price float64) {
+		if price > l.ARA {
+			flags = append(flags, LimitFlag{Field: field, Price: price, Limit: l.ARA,
+				Message: fmt.Sprintf("%s is above today's ARA of Rp %.0f and cannot be reached in one session", field, l.ARA)})
+		}
+	}
+	below := func(field string, price float64) {
+		if price > 0 && price < l.ARB {
+			flags = append(flags, LimitFlag{Field: field, Price: price, Limit: l.ARB,
+				Message: fmt.Sprintf("%s is below today's ARB of Rp %.0f and cannot trade this session", field, l.ARB)})
+		}
+	}
+
+	below("entry_low", entry.Low)
+	above("entry_high", entry.High)
+	for _, field := range []string{"target", "target_1", "target_2"} {
+		if price, ok := targets[field]; ok {
+			above(field, price)
+		}
+	}
+	below("stop_loss", stop)
+	return flags
+}
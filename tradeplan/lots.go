@@ -0,0 +1,34 @@
+package tradeplan
+
+import "stock-analysis-api/idx"
+
+// SizeLots turns the PositionSize of a BUY plan into whole lots bought at
+// the top of the entry zone, so the budget covers any fill. PositionSize
+// becomes the cost of those lots; Position keeps the budget and the
+// leftover cash.
+func (p *TradePlan) SizeLots() {
+	if p.Decision != DecisionBuy {
+		return
+	}
+	pos, err := idx.Size(p.PositionSize, p.Entry.High)
+	if err != nil {
+		return
+	}
+	p.Position = &pos
+	p.PositionSize = pos.Cost
+}
+
+// SizeLots does the same for a daily pick and its Size, falling back to
+// the current price when the pick has no entry zone.
+func (p *Pick) SizeLots() {
+	price := p.Entry.High
+	if price <= 0 {
+		price = p.Price
+	}
+	pos, err := idx.Size(p.Size, price)
+	if err != nil {
+		return
+	}
+	p.Position = &pos
+	p.Size = pos.Cost
+}
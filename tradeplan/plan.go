@@ -0,0 +1,95 @@
+// Package tradeplan holds the typed trade plan returned next to the
+// free-form analysis, and the code that produces it from the model.
+package tradeplan
+
+import (
+	"fmt"
+
+	"stock-analysis-api/idx"
+	"stock-analysis-api/llm"
+)
+
+// Entry decisions.
+const (
+	DecisionBuy   = "BUY"
+	DecisionHold  = "HOLD"
+	DecisionAvoid = "AVOID"
+)
+
+// Capital is the trading capital stated in the personas, in rupiah.
+const Capital = 7_500_000
+
+// PriceRange is an inclusive rupiah range.
+type PriceRange struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// TradePlan is the actionable part of an analysis. Price levels and the
+// position size are in rupiah and are zero when Decision is not BUY.
+type TradePlan struct {
+	Decision     string     `json:"decision"`
+	Entry        PriceRange `json:"entry"`
+	Target1      float64    `json:"target_1"`
+	Target2      float64    `json:"target_2"`
+	StopLoss     float64    `json:"stop_loss"`
+	PositionSize float64    `json:"position_size"`
+	Timeline     string     `json:"timeline"`
+	// Confidence runs from 1 (guess) to 10 (high conviction).
+	Confidence  int      `json:"confidence"`
+	RiskFactors []string `json:"risk_factors"`
+	// Unrounded holds the levels as the model gave them once the plan was
+	// rounded to IDX ticks by RoundToTicks.
+	Unrounded *PlanLevels `json:"unrounded,omitempty"`
+	// Position is PositionSize in whole lots, set by SizeLots.
+	Position *idx.Position `json:"position,omitempty"`
+	// Limits are the session's ARA/ARB bounds from the previous close and
+	// LimitFlags the levels outside them, set by CheckLimits.
+	Limits     *idx.Limits `json:"limits,omitempty"`
+	LimitFlags []LimitFlag `json:"limit_flags,omitempty"`
+}
+
+// Schema is the response schema the model fills in.
+func Schema() *llm.Schema {
+	rupiah := func(desc string) *llm.Schema {
+		return &llm.Schema{Type: llm.TypeNumber, Description: desc}
+	}
+	return &llm.Schema{
+		Type: llm.TypeObject,
+		Properties: map[string]*llm.Schema{
+			"decision": {
+				Type: llm.TypeString,
+				Enum: []string{DecisionBuy, DecisionHold, DecisionAvoid},
+			},
+			"entry": {
+				Type: llm.TypeObject,
+				Properties: map[string]*llm.Schema{
+					"low":  rupiah("Lower bound of the entry zone in rupiah"),
+					"high": rupiah("Upper bound of the entry zone in rupiah"),
+				},
+				Required: []string{"low", "high"},
+			},
+			"target_1":      rupiah("First target (about 4% above entry) in rupiah"),
+			"target_2":      rupiah("Second target (about 5% above entry) in rupiah"),
+			"stop_loss":     rupiah("Stop loss level in rupiah"),
+			"position_size": rupiah("Amount to deploy in rupiah"),
+			"timeline":      {Type: llm.TypeString, Description: "Holding period, e.g. \"1-3 days\""},
+			"confidence":    {Type: llm.TypeInteger, Description: "Conviction from 1 to 10"},
+			"risk_factors":  {Type: llm.TypeArray, Items: &llm.Schema{Type: llm.TypeString}},
+		},
+		Required: []string{"decision", "entry", "target_1", "target_2", "stop_loss", "position_size", "timeline", "confidence", "risk_factors"},
+	}
+}
+
+// Validate checks the fields the schema cannot constrain on every provider.
+func (p *TradePlan) Validate() error {
+	switch p.Decision {
+	case DecisionBuy, DecisionHold, DecisionAvoid:
+	default:
+		return fmt.Errorf("invalid trade plan: unknown decision %q", p.Decision)
+	}
+	if p.Confidence < 1 || p.Confidence > 10 {
+		return fmt.Errorf("invalid trade plan: confidence %d is outside 1-10", p.Confidence)
+	}
+	return nil
+}
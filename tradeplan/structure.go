@@ -0,0 +1,74 @@
+package tradeplan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"stock-analysis-api/llm"
+)
+
+// Structure asks the model to transcribe a finished analysis into a
+// TradePlan. It runs as a separate JSON-mode call because Gemini does not
+// combine function calling with a response schema. The returned Response
+// carries the usage of this call.
+func Structure(ctx context.Context, client *llm.Client, analysis string) (*TradePlan, *llm.Response, error) {
+	prompt := `Ubah rekomendasi trading dalam analisis berikut menjadi JSON dengan field:
+decision (BUY/HOLD/AVOID), entry {low, high}, target_1, target_2, stop_loss,
+position_size, timeline, confidence (1-10), risk_factors (array string).
+
+Semua harga dan position_size dalam rupiah sebagai angka tanpa pemisah ribuan.
+Jika decision bukan BUY, isi level harga dan position_size dengan 0.
+Jangan menambah angka yang tidak ada di analisis.
+
+ANALISIS:
+` + analysis
+
+	var plan TradePlan
+	resp, err := structure(ctx, client, prompt, Schema(), &plan)
+	if err != nil {
+		return nil, resp, err
+	}
+	if err := plan.Validate(); err != nil {
+		return nil, resp, err
+	}
+	return &plan, resp, nil
+}
+
+// structure runs prompt on the "trade-plan" endpoint in JSON mode and
+// decodes the reply into v.
+func structure(ctx context.Context, client *llm.Client, prompt string, schema *llm.Schema, v interface{}) (*llm.Response, error) {
+	generation, err := client.ResolveGeneration("trade-plan", nil)
+	if err != nil {
+		return nil, err
+	}
+	generation.ResponseMimeType = llm.MimeJSON
+	generation.ResponseSchema = schema
+
+	resp, err := client.Generate(ctx, &llm.Request{
+		Endpoint:         "trade-plan",
+		Contents:         llm.UserText(prompt),
+		GenerationConfig: generation,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(stripFence(resp.Text)), v); err != nil {
+		return resp, fmt.Errorf("failed to parse structured output: %v", err)
+	}
+	return resp, nil
+}
+
+// stripFence removes a ```json fence that providers without a native JSON
+// mode sometimes wrap around the object.
+func stripFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	return strings.TrimSpace(strings.TrimSuffix(text, "```"))
+}
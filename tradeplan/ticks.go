@@ -0,0 +1,64 @@
+package tradeplan
+
+import "stock-analysis-api/idx"
+
+// PlanLevels are the price levels of a TradePlan before tick rounding.
+type PlanLevels struct {
+	Entry    PriceRange `json:"entry"`
+	Target1  float64    `json:"target_1"`
+	Target2  float64    `json:"target_2"`
+	StopLoss float64    `json:"stop_loss"`
+}
+
+// PickLevels are the price levels of a Pick before tick rounding.
+type PickLevels struct {
+	Entry    PriceRange `json:"entry"`
+	Target   float64    `json:"target"`
+	StopLoss float64    `json:"stop_loss"`
+}
+
+// RoundToTicks moves every price level of a BUY plan onto a valid IDX tick
+// and keeps the model's levels in Unrounded. See roundLevels for the
+// directions. It runs before Repair, which fixes and reports a stop the
+// model put at or above the entry.
+func (p *TradePlan) RoundToTicks() {
+	if p.Decision != DecisionBuy {
+		return
+	}
+	p.Unrounded = &PlanLevels{Entry: p.Entry, Target1: p.Target1, Target2: p.Target2, StopLoss: p.StopLoss}
+	p.Target1 = idx.RoundDown(p.Target1)
+	p.Target2 = idx.RoundDown(p.Target2)
+	p.StopLoss = roundLevels(&p.Entry, p.StopLoss)
+}
+
+// RoundToTicks does the same for a daily pick, keeping the model's levels
+// in Unrounded.
+func (p *Pick) RoundToTicks() {
+	p.Unrounded = &PickLevels{Entry: p.Entry, Target: p.Target, StopLoss: p.StopLoss}
+	p.Target = idx.RoundDown(p.Target)
+	p.StopLoss = roundLevels(&p.Entry, p.StopLoss)
+	// Picks are not repaired, so a stop at or above the entry is moved
+	// here.
+	if p.Entry.Low > 0 && p.StopLoss >= p.Entry.Low {
+		p.StopLoss = idx.TickBelow(p.Entry.Low)
+	}
+}
+
+// roundLevels rounds the entry zone down, so the bid is never above what
+// the model asked for, and returns the stop rounded up, which keeps the
+// loss within the model's level. Targets are rounded down by the callers
+// so they stay reachable. A stop that only reaches the entry through
+// rounding is put one tick below it.
+func roundLevels(entry *PriceRange, stop float64) float64 {
+	below := stop < entry.Low
+	entry.Low = idx.RoundDown(entry.Low)
+	entry.High = idx.RoundDown(entry.High)
+	if stop <= 0 {
+		return stop
+	}
+	stop = idx.RoundUp(stop)
+	if below && stop >= entry.Low {
+		stop = idx.TickBelow(entry.Low)
+	}
+	return stop
+}